@@ -0,0 +1,378 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 管理接口中的客户端信息（不暴露真实IP）
+type clientInfo struct {
+	ID             uint64     `json:"id"`
+	UserID         string     `json:"userId"`
+	IP             string     `json:"ip"`
+	Region         string     `json:"region"`
+	ConnectedSince time.Time  `json:"connectedSince"`
+	Transport      string     `json:"transport"` // websocket/sse/ssh/tcp/irc
+	BytesIn        int64      `json:"bytesIn"`
+	BytesOut       int64      `json:"bytesOut"`
+	WireBytesIn    int64      `json:"wireBytesIn"`  // 线路上实际收到的字节数（压缩后）
+	WireBytesOut   int64      `json:"wireBytesOut"` // 线路上实际发出的字节数（压缩后）
+	Compressed     bool       `json:"compressed"`
+	MutedUntil     *time.Time `json:"mutedUntil,omitempty"`
+}
+
+// 管理接口中的房间信息
+type roomInfo struct {
+	Name    string `json:"name"`
+	Members int    `json:"members"`
+	Bots    int    `json:"bots"`
+}
+
+// 关闭计划信息
+type shutdownInfo struct {
+	Scheduled        bool       `json:"scheduled"`
+	RemainingMinutes int        `json:"remainingMinutes"` // 向上取整的分钟数
+	RemainingSeconds int        `json:"remainingSeconds"` // 向上取整的秒数
+	ShutdownAt       *time.Time `json:"shutdownAt,omitempty"`
+}
+
+// 管理操作的请求参数
+type adminRequest struct {
+	Minutes int    `json:"minutes"` // 禁言/封禁/关闭的分钟数
+	Delay   string `json:"delay"`   // 关闭时间，语法同 /close（如 90s、1h30m、at 18:00），优先于 minutes
+	Content string `json:"content"` // 公告内容
+}
+
+// 注册管理接口路由，全部需要Bearer Token鉴权
+func (s *ChatServer) RegisterAdminRoutes(mux *http.ServeMux, token string) {
+	mux.HandleFunc("GET /api/admin/clients", requireBearer(token, s.handleListClients))
+	mux.HandleFunc("GET /api/admin/rooms", requireBearer(token, s.handleListRooms))
+	mux.HandleFunc("POST /api/admin/clients/{id}/kick", requireBearer(token, s.handleKick))
+	mux.HandleFunc("POST /api/admin/clients/{id}/ban", requireBearer(token, s.handleBan))
+	mux.HandleFunc("POST /api/admin/clients/{id}/mute", requireBearer(token, s.handleMute))
+	mux.HandleFunc("DELETE /api/admin/clients/{id}/mute", requireBearer(token, s.handleUnmute))
+	mux.HandleFunc("POST /api/admin/announce", requireBearer(token, s.handleAnnounce))
+	mux.HandleFunc("GET /api/admin/shutdown", requireBearer(token, s.handleGetShutdown))
+	mux.HandleFunc("POST /api/admin/shutdown", requireBearer(token, s.handleScheduleShutdown))
+	mux.HandleFunc("DELETE /api/admin/shutdown", requireBearer(token, s.handleCancelShutdown))
+}
+
+// 解析 -trusted-proxies 参数：逗号分隔的IP或网段，例如 127.0.0.1,10.0.0.0/8
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("可信代理 %q 不是合法的IP或网段", part)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("可信代理 %q 不是合法的IP或网段", part)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// 封禁客户端时使用的IP：X-Forwarded-For 可以由客户端伪造，只有连接来自可信代理时才使用
+// 转发头中的IP，否则使用连接的对端IP
+func (s *ChatServer) banIP(c *Client) string {
+	addr, err := netip.ParseAddr(c.remoteIP)
+	if err != nil {
+		return c.remoteIP
+	}
+	addr = addr.Unmap()
+	for _, proxy := range s.trustedProxies {
+		if proxy.Contains(addr) {
+			return c.IP
+		}
+	}
+	return c.remoteIP
+}
+
+// 判断IP是否处于封禁期，已过期的封禁顺便清除
+func (s *ChatServer) isBanned(ip string) bool {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	until, ok := s.bans[ip]
+	if !ok {
+		return false
+	}
+	if !until.IsZero() && !s.clock.Now().Before(until) {
+		delete(s.bans, ip)
+		return false
+	}
+	return true
+}
+
+// 判断客户端是否处于禁言期
+func (s *ChatServer) isMuted(c *Client) bool {
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	return s.clock.Now().Before(c.mutedUntil)
+}
+
+// 按会话编号查找在线客户端
+func (s *ChatServer) findClient(id uint64) *Client {
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	return s.clients[id]
+}
+
+// 踢出客户端：通知后关闭连接，离开消息由 HandleClient 广播
+func (s *ChatServer) kickClient(c *Client, reason string) {
+	c.kicked.Store(true)
+	c.Send(Message{
+		Type:    "system",
+		Content: "【系统通知】" + reason,
+		Time:    s.timestamp(),
+	})
+	c.transport.Close()
+}
+
+// 解析路径中的客户端编号，找不到时直接写出错误响应
+func (s *ChatServer) clientFromPath(w http.ResponseWriter, r *http.Request) *Client {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "客户端编号无效"})
+		return nil
+	}
+	c := s.findClient(id)
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "客户端不在线"})
+	}
+	return c
+}
+
+// 禁言/封禁等管理操作的最长分钟数（一年），更大的值换算成 time.Duration 可能溢出
+const maxAdminMinutes = 365 * 24 * 60
+
+// 解析可选的JSON请求体（空请求体视为默认参数）
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request) (adminRequest, bool) {
+	var req adminRequest
+	if r.ContentLength == 0 {
+		return req, true
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "请求体不是有效的JSON"})
+		return req, false
+	}
+	if req.Minutes < 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "minutes 不能为负数"})
+		return req, false
+	}
+	if req.Minutes > maxAdminMinutes {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("minutes 不能超过 %d（一年）", maxAdminMinutes)})
+		return req, false
+	}
+	return req, true
+}
+
+func (s *ChatServer) handleListClients(w http.ResponseWriter, r *http.Request) {
+	s.clientsMutex.RLock()
+	list := make([]clientInfo, 0, len(s.clients))
+	for _, c := range s.clients {
+		info := clientInfo{
+			ID:             c.ID,
+			UserID:         c.UserID,
+			IP:             maskIP(c.IP),
+			Region:         c.Region,
+			ConnectedSince: c.ConnectedAt,
+			Transport:      c.transport.Name(),
+			BytesIn:        c.BytesIn.Load(),
+			BytesOut:       c.BytesOut.Load(),
+			WireBytesIn:    c.WireBytesIn.Load(),
+			WireBytesOut:   c.WireBytesOut.Load(),
+			Compressed:     c.Compressed(),
+		}
+		if s.clock.Now().Before(c.mutedUntil) {
+			mutedUntil := c.mutedUntil
+			info.MutedUntil = &mutedUntil
+		}
+		list = append(list, info)
+	}
+	s.clientsMutex.RUnlock()
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *ChatServer) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	s.clientsMutex.RLock()
+	room := roomInfo{Name: defaultRoom, Members: len(s.clients), Bots: len(s.bots)}
+	s.clientsMutex.RUnlock()
+	writeJSON(w, http.StatusOK, []roomInfo{room})
+}
+
+func (s *ChatServer) handleKick(w http.ResponseWriter, r *http.Request) {
+	c := s.clientFromPath(w, r)
+	if c == nil {
+		return
+	}
+	s.kickClient(c, "你已被管理员踢出聊天室")
+	s.logger.Info("管理员踢出用户", s.logAttrs("admin_kick", c.UserID, c.IP)...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 封禁客户端IP（minutes 为0表示直到服务重启），并踢出该IP下的所有连接
+func (s *ChatServer) handleBan(w http.ResponseWriter, r *http.Request) {
+	c := s.clientFromPath(w, r)
+	if c == nil {
+		return
+	}
+	req, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+	var until time.Time
+	if req.Minutes > 0 {
+		until = s.clock.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	}
+
+	ip := s.banIP(c)
+	s.clientsMutex.Lock()
+	s.bans[ip] = until
+	var targets []*Client
+	for _, other := range s.clients {
+		if s.banIP(other) == ip {
+			targets = append(targets, other)
+		}
+	}
+	s.clientsMutex.Unlock()
+
+	for _, t := range targets {
+		s.kickClient(t, "你已被管理员封禁")
+	}
+	s.logger.Info("管理员封禁IP", append(s.logAttrs("admin_ban", c.UserID, ip), "minutes", req.Minutes, "kicked", len(targets))...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 禁言客户端，默认10分钟
+func (s *ChatServer) handleMute(w http.ResponseWriter, r *http.Request) {
+	c := s.clientFromPath(w, r)
+	if c == nil {
+		return
+	}
+	req, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Minutes == 0 {
+		req.Minutes = 10
+	}
+	until := s.clock.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	s.clientsMutex.Lock()
+	c.mutedUntil = until
+	s.clientsMutex.Unlock()
+
+	c.Send(Message{
+		Type:    "system",
+		Content: fmt.Sprintf("【系统通知】你已被管理员禁言 %d 分钟", req.Minutes),
+		Time:    s.timestamp(),
+	})
+	s.logger.Info("管理员禁言用户", append(s.logAttrs("admin_mute", c.UserID, c.IP), "minutes", req.Minutes)...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ChatServer) handleUnmute(w http.ResponseWriter, r *http.Request) {
+	c := s.clientFromPath(w, r)
+	if c == nil {
+		return
+	}
+	s.clientsMutex.Lock()
+	c.mutedUntil = time.Time{}
+	s.clientsMutex.Unlock()
+
+	c.Send(Message{
+		Type:    "system",
+		Content: "【系统通知】你的禁言已解除",
+		Time:    s.timestamp(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 广播管理员公告
+func (s *ChatServer) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+	content, err := sanitizeChatContent(req.Content)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	msg := Message{
+		Type:    "system",
+		Content: "【管理员公告】" + content,
+		Time:    s.timestamp(),
+	}
+	s.publish(msg)
+	writeJSON(w, http.StatusAccepted, msg)
+}
+
+func (s *ChatServer) handleGetShutdown(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.shutdownInfo())
+}
+
+// 当前关闭计划的查询结果
+func (s *ChatServer) shutdownInfo() shutdownInfo {
+	at, scheduled := s.ShutdownAt()
+	if !scheduled {
+		return shutdownInfo{}
+	}
+	remaining := max(at.Sub(s.clock.Now()), 0)
+	return shutdownInfo{
+		Scheduled:        true,
+		RemainingMinutes: int((remaining + time.Minute - 1) / time.Minute),
+		RemainingSeconds: int((remaining + time.Second - 1) / time.Second),
+		ShutdownAt:       &at,
+	}
+}
+
+func (s *ChatServer) handleScheduleShutdown(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAdminRequest(w, r)
+	if !ok {
+		return
+	}
+	var delay time.Duration
+	if req.Delay != "" {
+		var err error
+		if delay, err = parseShutdownDelay(req.Delay, s.clock.Now()); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+	} else if req.Minutes <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "minutes 必须大于0"})
+		return
+	} else if req.Minutes > int(maxShutdownDelay/time.Minute) {
+		// 先比较分钟数再换算，过大的分钟数换算成 time.Duration 会溢出
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errShutdownDelayTooLong.Error()})
+		return
+	} else {
+		delay = time.Duration(req.Minutes) * time.Minute
+	}
+	s.ScheduleShutdown(delay)
+	s.logger.Info("管理员设置关闭时间", "event", "admin_shutdown", "delay", delay.String())
+	writeJSON(w, http.StatusOK, s.shutdownInfo())
+}
+
+func (s *ChatServer) handleCancelShutdown(w http.ResponseWriter, r *http.Request) {
+	if !s.CancelShutdown() {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "未设置关闭时间"})
+		return
+	}
+	s.logger.Info("管理员取消关闭计划", "event", "admin_shutdown_cancel")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+// 聊天室目前只有一个房间，HTTP接口中的房间名固定为此值
+const defaultRoom = "lobby"
+
+// 接口错误返回格式
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Bearer Token 鉴权中间件；token 为空时接口关闭
+func requireBearer(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "接口未启用"})
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		given, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chatroom"`)
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "未授权"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// 入站消息请求体
+type postMessageRequest struct {
+	Type    string `json:"type"`    // system（系统通知）或 chat（以机器人身份发言），默认 system
+	Content string `json:"content"` // 消息内容
+	UserID  string `json:"userId"`  // type 为 chat 时的发言者名称，默认 webhook
+}
+
+// 根据名称挑选固定的颜色，同一名称每次颜色一致
+func colorForName(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return colors[h.Sum32()%uint32(len(colors))]
+}
+
+// 入站Webhook：POST /api/rooms/{room}/messages，向聊天室投递一条消息
+func (s *ChatServer) HandlePostMessage(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("room") != defaultRoom {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "房间不存在"})
+		return
+	}
+
+	var req postMessageRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "请求体不是有效的JSON"})
+		return
+	}
+
+	// 与用户聊天消息相同的校验和转义
+	content, err := sanitizeChatContent(req.Content)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	msg := Message{Time: s.timestamp()}
+	switch req.Type {
+	case "", "system":
+		msg.Type = "system"
+		msg.Content = "【系统通知】" + content
+	case "chat":
+		name, err := sanitizeChatContent(req.UserID)
+		if err == errEmptyMessage {
+			name = "webhook"
+		} else if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "userId 无效"})
+			return
+		}
+		msg.Type = "chat"
+		msg.Content = content
+		msg.UserID = name
+		msg.IP = botIP
+		msg.Region = botRegion
+		msg.Color = colorForName(name)
+	default:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "type 只能是 system 或 chat"})
+		return
+	}
+
+	s.publish(msg)
+	writeJSON(w, http.StatusAccepted, msg)
+}
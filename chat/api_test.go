@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testAPIToken = "api-token"
+
+// 调用入站消息接口，token 为空时不带鉴权头，返回状态码
+func (ts *testServer) postMessage(t *testing.T, room, token, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.httpURL+"/api/rooms/"+room+"/messages", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("调用入站消息接口失败：%v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestPostMessageAuth(t *testing.T) {
+	ts := newTestServer(t)
+	ts.SetAPIToken(testAPIToken)
+	alice := ts.login(t, "alice")
+
+	body := `{"content":"不应送达"}`
+	for _, token := range []string{"", "wrong-token", testAdminToken} {
+		if code := ts.postMessage(t, defaultRoom, token, body); code != http.StatusUnauthorized {
+			t.Errorf("Token %q：状态码 = %d，应为 401", token, code)
+		}
+	}
+
+	// 被拒绝的请求不会广播：下一条收到的就是之后自己发的消息
+	alice.chat("标记")
+	alice.expectFunc("chat 标记", func(m Message) bool {
+		if strings.Contains(m.Content, "不应送达") {
+			t.Errorf("未授权的请求被广播：%+v", m)
+		}
+		return m.Type == "chat" && m.Content == "标记"
+	})
+}
+
+func TestPostMessageDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	if code := ts.postMessage(t, defaultRoom, testAPIToken, `{"content":"hi"}`); code != http.StatusNotFound {
+		t.Errorf("未设置Token时状态码 = %d，应为 404", code)
+	}
+}
+
+func TestPostMessageBadRequest(t *testing.T) {
+	ts := newTestServer(t)
+	ts.SetAPIToken(testAPIToken)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{``, http.StatusBadRequest},
+		{`{"content":`, http.StatusBadRequest},
+		{`["content"]`, http.StatusBadRequest},
+		{`{"content":"   "}`, http.StatusBadRequest},
+		{`{"content":"\u001b[2J"}`, http.StatusBadRequest},
+		{`{"type":"private","content":"hi"}`, http.StatusBadRequest},
+		{`{"type":"chat","content":"hi","userId":"\u0007"}`, http.StatusBadRequest},
+		{`{"content":"` + strings.Repeat("长", 64<<10) + `"}`, http.StatusBadRequest},
+	} {
+		if code := ts.postMessage(t, defaultRoom, testAPIToken, tc.body); code != tc.want {
+			t.Errorf("请求体 %.40q：状态码 = %d，应为 %d", tc.body, code, tc.want)
+		}
+	}
+	if code := ts.postMessage(t, "other", testAPIToken, `{"content":"hi"}`); code != http.StatusNotFound {
+		t.Errorf("不存在的房间：状态码 = %d，应为 404", code)
+	}
+}
+
+func TestPostMessageBroadcast(t *testing.T) {
+	ts := newTestServer(t)
+	ts.SetAPIToken(testAPIToken)
+	alice := ts.login(t, "alice")
+
+	if code := ts.postMessage(t, defaultRoom, testAPIToken, `{"content":"部署完成 <b>v2</b>"}`); code != http.StatusAccepted {
+		t.Fatalf("状态码 = %d，应为 202", code)
+	}
+	alice.expect("system", "【系统通知】部署完成 &lt;b&gt;v2&lt;/b&gt;")
+
+	if code := ts.postMessage(t, defaultRoom, testAPIToken, `{"type":"chat","content":"构建失败","userId":"ci"}`); code != http.StatusAccepted {
+		t.Fatalf("状态码 = %d，应为 202", code)
+	}
+	m := alice.expect("chat", "构建失败")
+	if m.UserID != "ci" || m.IP != botIP || m.Color != colorForName("ci") {
+		t.Errorf("机器人消息 = %+v", m)
+	}
+
+	// 未指定发言者时使用 webhook
+	ts.postMessage(t, defaultRoom, testAPIToken, `{"type":"chat","content":"匿名"}`)
+	if m := alice.expect("chat", "匿名"); m.UserID != "webhook" {
+		t.Errorf("默认发言者 = %q，应为 webhook", m.UserID)
+	}
+}
@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"fmt"
+
+	"demogo/clock"
+)
+
+// 机器人接口：以伪客户端身份加入聊天室（无需WebSocket连接），观察广播消息并响应触发词
+type Bot interface {
+	// 机器人的用户ID（显示在聊天和在线列表中）
+	Name() string
+	// 收到一条广播消息；需要回复时调用 say 发送群聊消息（say 可在之后异步调用）
+	OnMessage(msg Message, say func(content string))
+}
+
+// 需要计时的机器人，加入聊天室时改用聊天室的时钟
+type clockedBot interface {
+	setClock(c clock.Clock)
+}
+
+// 持有定时器等资源的机器人，聊天室停止时释放
+type stoppableBot interface {
+	stop()
+}
+
+// 机器人在聊天室中的伪客户端
+type botClient struct {
+	bot    Bot
+	Client *Client      // 在线列表中展示的客户端信息
+	inbox  chan Message // 待处理的广播消息，防止慢机器人阻塞广播
+}
+
+// 机器人的展示信息
+const (
+	botIP     = "bot"
+	botRegion = "机器人"
+)
+
+// 注册机器人并加入聊天室（可在任意时刻调用，聊天室停止后调用无效）
+func (s *ChatServer) AddBot(bot Bot) {
+	if cb, ok := bot.(clockedBot); ok {
+		cb.setClock(s.clock)
+	}
+	bc := &botClient{
+		bot: bot,
+		Client: &Client{
+			UserID: bot.Name(),
+			IP:     botIP,
+			Region: botRegion,
+			Color:  s.generateRandomColor(),
+		},
+		inbox: make(chan Message, 64),
+	}
+
+	s.clientsMutex.Lock()
+	if s.stopped() {
+		s.clientsMutex.Unlock()
+		return
+	}
+	s.bots = append(s.bots, bc)
+	s.clientsMutex.Unlock()
+
+	go s.runBot(bc)
+	s.logger.Info("机器人加入聊天室", "event", "bot_join", "room", defaultRoom, "user", bot.Name())
+}
+
+// 机器人消息处理循环，消息队列关闭（聊天室停止）后结束
+func (s *ChatServer) runBot(bc *botClient) {
+	say := func(content string) {
+		if content == "" {
+			return
+		}
+		s.publish(Message{
+			Type:    "chat",
+			Content: escapeHTML(content),
+			UserID:  bc.Client.UserID,
+			IP:      bc.Client.IP,
+			Region:  bc.Client.Region,
+			Time:    s.timestamp(),
+			Color:   bc.Client.Color,
+		})
+	}
+	for msg := range bc.inbox {
+		// 忽略自己发出的消息，防止机器人自问自答
+		if msg.UserID == bc.Client.UserID && msg.IP == botIP {
+			continue
+		}
+		bc.bot.OnMessage(msg, say)
+	}
+}
+
+// 把广播消息分发给所有机器人（非阻塞，机器人处理不过来时丢弃）
+func (s *ChatServer) dispatchToBots(msg Message) {
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	for _, bc := range s.bots {
+		select {
+		case bc.inbox <- msg:
+		default:
+			s.logger.Warn("机器人消息队列已满，丢弃消息", "event", "bot_drop", "user", bc.Client.UserID)
+		}
+	}
+}
+
+// 聊天室停止时移除所有机器人并关闭消息队列，结束各自的处理循环，并释放机器人的定时器
+func (s *ChatServer) stopBots() {
+	s.clientsMutex.Lock()
+	bots := s.bots
+	s.bots = nil
+	s.clientsMutex.Unlock()
+	for _, bc := range bots {
+		close(bc.inbox)
+		if sb, ok := bc.bot.(stoppableBot); ok {
+			sb.stop()
+		}
+	}
+}
+
+// 在线列表中机器人的行（带机器人标记）
+func (s *ChatServer) botOnlineLines() string {
+	lines := ""
+	for _, bc := range s.bots {
+		lines += fmt.Sprintf("%-15s | %-28s | %s [BOT]\n", bc.Client.IP, bc.Client.Region, bc.Client.UserID)
+	}
+	return lines
+}
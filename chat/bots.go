@@ -0,0 +1,204 @@
+package chat
+
+import (
+	"fmt"
+	"html"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"demogo/clock"
+)
+
+// 复读机器人：/echo 内容 —— 原样复述
+type EchoBot struct{}
+
+func NewEchoBot() *EchoBot { return &EchoBot{} }
+
+func (b *EchoBot) Name() string { return "复读机" }
+
+func (b *EchoBot) OnMessage(msg Message, say func(content string)) {
+	if msg.Type != "chat" {
+		return
+	}
+	// 广播内容已经过HTML转义，先还原，say 会重新转义
+	content := html.UnescapeString(msg.Content)
+	if !strings.HasPrefix(content, "/echo ") {
+		return
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(content, "/echo "))
+	if text != "" {
+		say(text)
+	}
+}
+
+// 提醒的最长时间，以及每个用户同时等待的提醒数上限
+const (
+	maxRemindDuration   = 24 * time.Hour
+	maxPendingReminders = 5
+)
+
+// 提醒机器人：/remind 10m 喝水 —— 到时间后在群里提醒
+type RemindBot struct {
+	clock   clock.Clock
+	mu      sync.Mutex
+	pending map[string]int           // 每个用户等待中的提醒数
+	timers  map[clock.Timer]struct{} // 等待中的提醒，聊天室停止时取消
+	stopped bool
+}
+
+func NewRemindBot() *RemindBot {
+	return &RemindBot{
+		clock:   clock.Real,
+		pending: make(map[string]int),
+		timers:  make(map[clock.Timer]struct{}),
+	}
+}
+
+func (b *RemindBot) setClock(c clock.Clock) { b.clock = c }
+
+func (b *RemindBot) Name() string { return "提醒助手" }
+
+func (b *RemindBot) OnMessage(msg Message, say func(content string)) {
+	if msg.Type != "chat" {
+		return
+	}
+	content := html.UnescapeString(msg.Content)
+	if content != "/remind" && !strings.HasPrefix(content, "/remind ") {
+		return
+	}
+	parts := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(content, "/remind")), " ", 2)
+	if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+		say("用法：/remind 时长 内容，例如 /remind 10m 喝水")
+		return
+	}
+	d, err := time.ParseDuration(parts[0])
+	if err != nil || d <= 0 || d > maxRemindDuration {
+		say("❌ 时长无效，支持 30s、10m、1h 等格式，最长 24h")
+		return
+	}
+	user := html.UnescapeString(msg.UserID)
+	text := strings.TrimSpace(parts[1])
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return
+	}
+	if b.pending[user] >= maxPendingReminders {
+		say(fmt.Sprintf("❌ %s 已有 %d 条提醒在等待，请等之前的提醒结束后再设置", user, maxPendingReminders))
+		return
+	}
+	say(fmt.Sprintf("好的 %s，%s 后提醒你：%s", user, d, text))
+	b.pending[user]++
+	// 持有锁设置定时器，回调要等 timer 记录完成后才能执行
+	var timer clock.Timer
+	timer = b.clock.AfterFunc(d, func() {
+		b.mu.Lock()
+		_, ok := b.timers[timer]
+		if ok {
+			delete(b.timers, timer)
+			b.release(user)
+		}
+		b.mu.Unlock()
+		if ok {
+			say(fmt.Sprintf("⏰ @%s 提醒：%s", user, text))
+		}
+	})
+	b.timers[timer] = struct{}{}
+}
+
+// 提醒结束后减少用户的等待数（调用方需持有 mu）
+func (b *RemindBot) release(user string) {
+	if b.pending[user]--; b.pending[user] <= 0 {
+		delete(b.pending, user)
+	}
+}
+
+// 取消所有等待中的提醒，之后不再接受新的提醒
+func (b *RemindBot) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	for timer := range b.timers {
+		timer.Stop()
+	}
+	clear(b.timers)
+	clear(b.pending)
+}
+
+// 骰子的最大个数和面数
+const (
+	maxDice     = 10
+	maxDiceFace = 100
+)
+
+// 骰子硬币机器人：/roll [NdM] 掷骰子，/flip 抛硬币
+type DiceBot struct{}
+
+func NewDiceBot() *DiceBot { return &DiceBot{} }
+
+func (b *DiceBot) Name() string { return "骰子" }
+
+func (b *DiceBot) OnMessage(msg Message, say func(content string)) {
+	if msg.Type != "chat" {
+		return
+	}
+	user := html.UnescapeString(msg.UserID)
+	fields := strings.Fields(html.UnescapeString(msg.Content))
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "/flip":
+		side := "正面"
+		if rand.Intn(2) == 1 {
+			side = "反面"
+		}
+		say(fmt.Sprintf("🪙 %s 抛出了硬币：%s", user, side))
+	case "/roll":
+		count, face := 1, 6
+		if len(fields) > 1 {
+			var ok bool
+			count, face, ok = parseDice(fields[1])
+			if !ok {
+				say(fmt.Sprintf("❌ 格式错误，例如 /roll 2d6（最多 %d 个骰子，%d 面）", maxDice, maxDiceFace))
+				return
+			}
+		}
+		rolls := make([]string, 0, count)
+		total := 0
+		for i := 0; i < count; i++ {
+			n := rand.Intn(face) + 1
+			total += n
+			rolls = append(rolls, strconv.Itoa(n))
+		}
+		say(fmt.Sprintf("🎲 %s 掷出 %dd%d：%s（合计 %d）", user, count, face, strings.Join(rolls, " + "), total))
+	}
+}
+
+// 解析 NdM 格式的骰子参数
+func parseDice(s string) (count, face int, ok bool) {
+	parts := strings.SplitN(strings.ToLower(s), "d", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	count = 1
+	if parts[0] != "" {
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, false
+		}
+		count = n
+	}
+	face, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	if count < 1 || count > maxDice || face < 2 || face > maxDiceFace {
+		return 0, 0, false
+	}
+	return count, face, true
+}
@@ -0,0 +1,123 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"demogo/transport/ws"
+	"github.com/gorilla/websocket"
+)
+
+// 广播扇出基准测试：对比逐个客户端编码（旧实现）和 PreparedMessage 一次编码
+//
+//	go test -run '^$' -bench Broadcast -benchmem
+
+var benchClientCounts = []int{100, 500}
+
+var benchMessage = Message{
+	Type:    "chat",
+	Content: strings.Repeat("摸鱼聊天消息", 20),
+	UserID:  "快乐小猫123",
+	IP:      "10:0:*",
+	Region:  "杭州",
+	Time:    "12:00:00",
+	Color:   "#00ff00",
+}
+
+// 建立 n 个进程内WebSocket连接，返回服务端一侧的客户端；客户端一侧持续读取并丢弃消息
+func setupBenchClients(b *testing.B, n int) (*ChatServer, []*Client) {
+	b.Helper()
+	s := NewChatServer("bench")
+	accepted := make(chan *Client, n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		codec := codecFor(conn.Subprotocol())
+		accepted <- &Client{transport: ws.New(conn, codec.FrameType()), codec: codec}
+	}))
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	var dialed []*websocket.Conn
+	for i := 0; i < n; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			b.Fatalf("连接失败: %v", err)
+		}
+		dialed = append(dialed, conn)
+		go func() {
+			for {
+				_, r, err := conn.NextReader()
+				if err != nil {
+					return
+				}
+				io.Copy(io.Discard, r)
+			}
+		}()
+	}
+
+	clients := make([]*Client, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case c := <-accepted:
+			clients = append(clients, c)
+		case <-time.After(5 * time.Second):
+			b.Fatalf("等待连接超时，已建立 %d 个", len(clients))
+		}
+	}
+
+	b.Cleanup(func() {
+		for _, c := range clients {
+			c.transport.Close()
+		}
+		for _, conn := range dialed {
+			conn.Close()
+		}
+		srv.Close()
+	})
+	return s, clients
+}
+
+func reportDeliveries(b *testing.B, n int) {
+	b.ReportMetric(float64(n)*float64(b.N)/b.Elapsed().Seconds(), "deliveries/s")
+}
+
+// 旧实现：每个客户端各自序列化一次
+func BenchmarkBroadcastPerClient(b *testing.B) {
+	for _, n := range benchClientCounts {
+		b.Run(fmt.Sprintf("clients=%d", n), func(b *testing.B) {
+			_, clients := setupBenchClients(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, c := range clients {
+					if err := c.Send(benchMessage); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			reportDeliveries(b, n)
+		})
+	}
+}
+
+// 新实现：每种编码格式只编码一次，复用 PreparedMessage
+func BenchmarkBroadcastPrepared(b *testing.B) {
+	for _, n := range benchClientCounts {
+		b.Run(fmt.Sprintf("clients=%d", n), func(b *testing.B) {
+			s, clients := setupBenchClients(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.fanOut(benchMessage, clients)
+			}
+			reportDeliveries(b, n)
+		})
+	}
+}
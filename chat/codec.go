@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 消息编解码器：通过WebSocket子协议协商，默认使用JSON
+type Codec interface {
+	// WebSocket子协议名
+	Name() string
+	// WebSocket帧类型（文本或二进制）
+	FrameType() int
+	Marshal(v interface{}) ([]byte, error)
+	// strict 为 true 时拒绝目标结构体中未定义的字段
+	Unmarshal(data []byte, v interface{}, strict bool) error
+}
+
+// 支持的子协议名
+const (
+	SubprotocolJSON    = "chatroom.json"
+	SubprotocolMsgpack = "chatroom.msgpack"
+	SubprotocolCBOR    = "chatroom.cbor"
+)
+
+// JSON编解码（默认，浏览器页面使用）
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string   { return SubprotocolJSON }
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// MessagePack编解码，字段名沿用 json 标签
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string   { return SubprotocolMsgpack }
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}, strict bool) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(strict)
+	return dec.Decode(v)
+}
+
+// CBOR编解码，字段名沿用 json 标签
+type cborCodec struct {
+	lenient cbor.DecMode
+	strict  cbor.DecMode
+}
+
+func newCBORCodec() *cborCodec {
+	lenient, err := cbor.DecOptions{}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	strict, err := cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return &cborCodec{lenient: lenient, strict: strict}
+}
+
+func (*cborCodec) Name() string   { return SubprotocolCBOR }
+func (*cborCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (*cborCodec) Marshal(v interface{}) ([]byte, error) { return cbor.Marshal(v) }
+
+func (c *cborCodec) Unmarshal(data []byte, v interface{}, strict bool) error {
+	if strict {
+		return c.strict.Unmarshal(data, v)
+	}
+	return c.lenient.Unmarshal(data, v)
+}
+
+// 所有编解码器，按子协议名索引
+var (
+	defaultCodec Codec = jsonCodec{}
+	codecs             = map[string]Codec{
+		SubprotocolJSON:    defaultCodec,
+		SubprotocolMsgpack: msgpackCodec{},
+		SubprotocolCBOR:    newCBORCodec(),
+	}
+)
+
+// 升级器可选的子协议，按服务端偏好排序（二进制优先）
+var codecSubprotocols = []string{SubprotocolMsgpack, SubprotocolCBOR, SubprotocolJSON}
+
+// 根据握手选定的子协议查找编解码器，未选定时使用JSON
+func codecFor(subprotocol string) Codec {
+	if c, ok := codecs[subprotocol]; ok {
+		return c
+	}
+	return defaultCodec
+}
@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"compress/flate"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"demogo/transport/ws"
+)
+
+// permessage-deflate 压缩配置
+type CompressionConfig struct {
+	Enabled   bool // 是否允许协商压缩
+	Level     int  // 压缩级别，1（最快）~ 9（最小）
+	Threshold int  // 小于该字节数的消息不压缩
+}
+
+// 默认压缩配置：长在线列表和粘贴的日志压缩效果明显，短消息不值得压缩
+var DefaultCompressionConfig = CompressionConfig{
+	Enabled:   true,
+	Level:     flate.BestSpeed,
+	Threshold: 256,
+}
+
+// 设置压缩配置（需在开始接受连接前调用）
+func (s *ChatServer) SetCompression(cfg CompressionConfig) error {
+	if cfg.Level < flate.BestSpeed || cfg.Level > flate.BestCompression {
+		return fmt.Errorf("压缩级别必须在 %d~%d 之间", flate.BestSpeed, flate.BestCompression)
+	}
+	if cfg.Threshold < 0 {
+		return fmt.Errorf("压缩阈值不能为负数")
+	}
+	s.compression = cfg
+	s.upgrader.EnableCompression = cfg.Enabled
+	return nil
+}
+
+// 客户端是否请求了 permessage-deflate（服务端开启压缩时即协商成功）
+func (s *ChatServer) compressionRequested(r *http.Request) bool {
+	if !s.compression.Enabled {
+		return false
+	}
+	for _, ext := range r.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// 客户端是否协商了压缩（只有WebSocket连接支持）
+func (c *Client) Compressed() bool {
+	t, ok := c.transport.(*ws.Transport)
+	return ok && t.Compressed()
+}
@@ -0,0 +1,947 @@
+package chat
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"demogo/clock"
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+// 端到端测试：在 httptest.Server 上启动聊天室，用脚本化的 WebSocket 客户端走完整流程
+
+const (
+	testPassword    = "Secret"
+	testAdminToken  = "admin-token"
+	testReadTimeout = 3 * time.Second
+)
+
+type testServer struct {
+	*ChatServer
+	clock   *clock.Fake
+	url     string // WebSocket地址
+	httpURL string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := NewChatServer(testPassword)
+	s.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	s.SetClock(fake)
+	s.SetAdminToken(testAdminToken)
+	s.Start()
+	srv := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Stop(ctx); err != nil {
+			t.Errorf("停止聊天室失败：%v", err)
+		}
+		srv.Close()
+	})
+	return &testServer{ChatServer: s, clock: fake, url: "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws", httpURL: srv.URL}
+}
+
+// 调用管理接口，返回状态码
+func (ts *testServer) admin(t *testing.T, method, path, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.httpURL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("调用管理接口失败：%v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// 等待假时钟上至少有 n 个定时器（定时器在其他协程中设置时使用）
+func (ts *testServer) waitTimers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(testReadTimeout)
+	for ts.clock.Pending() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待定时器超时：当前 %d 个，需要 %d 个", ts.clock.Pending(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 脚本化的测试客户端（v2 协议，与网页相同）
+type testClient struct {
+	t      *testing.T
+	conn   *websocket.Conn
+	codec  Codec  // 握手时协商的编码格式
+	userID string // 登录成功后的用户ID（已转义）
+}
+
+// 建立连接并完成握手，features 为 hello 中声明的可选功能；返回时已收到密码提示
+func (ts *testServer) dial(t *testing.T, features ...string) *testClient {
+	t.Helper()
+	c := ts.connect(t, nil)
+	c.handshake(features...)
+	return c
+}
+
+// 只建立WebSocket连接（可带额外请求头和子协议），不握手
+func (ts *testServer) connect(t *testing.T, header http.Header, subprotocols ...string) *testClient {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	conn, _, err := dialer.Dial(ts.url, header)
+	if err != nil {
+		t.Fatalf("连接聊天室失败：%v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, codec: codecFor(conn.Subprotocol())}
+}
+
+// 发送 v2 握手，返回时已收到密码提示和握手回复
+func (c *testClient) handshake(features ...string) {
+	c.t.Helper()
+	c.write(HelloPayload{Type: "hello", Versions: []int{ProtocolV2}, Features: features})
+	// 密码提示在连接建立后立即发出，握手回复在其后
+	c.expect("password", "请输入登录密码")
+	c.expect("hello", "协议 v2")
+}
+
+// 连接并登录，id 为空时使用随机ID；返回时已收到自己的加入广播
+func (ts *testServer) login(t *testing.T, id string, features ...string) *testClient {
+	t.Helper()
+	c := ts.dial(t, features...)
+	c.send("password", testPassword)
+	c.expect("password", "✅")
+	c.expect("setid", "请输入自定义ID")
+	c.send("setid", id)
+	welcome := c.expect("welcome", "登录成功")
+	c.userID = userIDFromWelcome(t, welcome.Content)
+	c.expectJoin(c.userID)
+	return c
+}
+
+// 从欢迎消息“你的信息：IP | 城市 | 用户ID”中取出用户ID
+func userIDFromWelcome(t *testing.T, content string) string {
+	t.Helper()
+	for _, line := range strings.Split(content, "\n") {
+		if info, ok := strings.CutPrefix(line, "你的信息："); ok {
+			fields := strings.Split(info, " | ")
+			return fields[len(fields)-1]
+		}
+	}
+	t.Fatalf("欢迎消息中没有用户信息：%q", content)
+	return ""
+}
+
+// 按协商的编码格式发送一条消息
+func (c *testClient) write(v interface{}) {
+	c.t.Helper()
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		c.t.Fatalf("编码消息失败：%v", err)
+	}
+	if err := c.conn.WriteMessage(c.codec.FrameType(), data); err != nil {
+		c.t.Fatalf("发送消息失败：%v", err)
+	}
+}
+
+func (c *testClient) send(kind, content string) {
+	c.t.Helper()
+	c.write(ChatPayload{Type: kind, Content: content})
+}
+
+func (c *testClient) chat(content string) {
+	c.t.Helper()
+	c.send("chat", content)
+}
+
+// 读取消息直到出现类型为 kind 且内容包含 contains 的消息，跳过其他消息
+func (c *testClient) expect(kind, contains string) Message {
+	c.t.Helper()
+	return c.expectFunc(kind+" "+contains, func(m Message) bool {
+		return m.Type == kind && strings.Contains(m.Content, contains)
+	})
+}
+
+func (c *testClient) expectJoin(userID string) Message {
+	c.t.Helper()
+	return c.expectFunc("join "+userID, func(m Message) bool {
+		return m.Type == "join" && m.UserID == userID
+	})
+}
+
+func (c *testClient) expectFunc(desc string, match func(Message) bool) Message {
+	c.t.Helper()
+	var seen []string
+	c.conn.SetReadDeadline(time.Now().Add(testReadTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		frameType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.t.Fatalf("等待消息 %q 失败：%v\n已收到：%s", desc, err, strings.Join(seen, "\n"))
+		}
+		if frameType != c.codec.FrameType() {
+			c.t.Fatalf("%s 消息应使用帧类型 %d，收到 %d", c.codec.Name(), c.codec.FrameType(), frameType)
+		}
+		var m Message
+		if err := c.codec.Unmarshal(data, &m, false); err != nil {
+			c.t.Fatalf("解码 %s 消息失败：%v", c.codec.Name(), err)
+		}
+		if match(m) {
+			return m
+		}
+		seen = append(seen, m.Type+" "+m.UserID+" "+m.Content)
+	}
+}
+
+// 连接应被服务端关闭（之前的消息全部跳过）
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(testReadTimeout))
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+				c.t.Fatal("连接没有被关闭")
+			}
+			return
+		}
+	}
+}
+
+func TestPasswordRetry(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.dial(t)
+
+	c.send("password", "   ")
+	c.expect("password", "❌ 密码不能为空")
+	c.send("password", "wrong")
+	c.expect("password", "❌ 密码错误")
+	// 密码不区分大小写，忽略首尾空白
+	c.send("password", "  sEcReT ")
+	c.expect("password", "✅ 密码验证成功")
+	c.expect("setid", "请输入自定义ID")
+}
+
+// v2 下格式错误的消息回复 error 事件，连接保持可用
+func TestProtocolV2Validation(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.dial(t)
+	raw := func(data string) {
+		t.Helper()
+		if err := c.conn.WriteMessage(websocket.TextMessage, []byte(data)); err != nil {
+			t.Fatalf("发送消息失败：%v", err)
+		}
+	}
+
+	raw(`{"type":"typing","content":"x"}`)
+	c.expect("error", `未知的消息类型："typing"`)
+	raw(`{"type":"password","content":"Secret","remember":true}`)
+	c.expect("error", "password 消息格式错误")
+	raw(`{"type":"password",`)
+	c.expect("error", "消息无法按 "+SubprotocolJSON+" 格式解析")
+	// 登录前发送聊天消息属于阶段错误
+	c.chat("抢先发言")
+	c.expect("error", "当前需要 password 消息，收到 chat")
+
+	// 上述错误都不影响后续登录
+	c.send("password", testPassword)
+	c.expect("password", "✅")
+	c.expect("setid", "请输入自定义ID")
+	c.chat("还没设置ID")
+	c.expect("error", "当前需要 setid 消息，收到 chat")
+	c.send("setid", "alice")
+	c.expectJoin(userIDFromWelcome(t, c.expect("welcome", "登录成功").Content))
+
+	raw(`{"type":"chat","content":"hi","to":"bob"}`)
+	c.expect("error", "chat 消息格式错误")
+	c.chat("正常发言")
+	c.expectFunc("chat 正常发言", func(m Message) bool {
+		return m.Type == "chat" && m.Content == "正常发言"
+	})
+}
+
+// 按子协议协商编码格式：二进制格式往返一条聊天消息，未知子协议回退为JSON
+func TestCodecSubprotocols(t *testing.T) {
+	ts := newTestServer(t)
+	for _, tc := range []struct {
+		offer []string
+		want  string
+	}{
+		{[]string{SubprotocolMsgpack}, SubprotocolMsgpack},
+		{[]string{SubprotocolCBOR}, SubprotocolCBOR},
+		{[]string{SubprotocolJSON, SubprotocolCBOR}, SubprotocolCBOR}, // 服务端偏好二进制格式
+		{[]string{"chatroom.xml"}, ""},
+	} {
+		c := ts.connect(t, nil, tc.offer...)
+		if got := c.conn.Subprotocol(); got != tc.want {
+			t.Fatalf("提供 %v 时协商结果 = %q，应为 %q", tc.offer, got, tc.want)
+		}
+		c.handshake()
+		c.send("password", testPassword)
+		c.expect("password", "✅")
+		c.expect("setid", "请输入自定义ID")
+		c.send("setid", "")
+		c.userID = userIDFromWelcome(t, c.expect("welcome", "登录成功").Content)
+		c.expectJoin(c.userID)
+
+		content := "你好，" + c.codec.Name()
+		c.chat(content)
+		c.expectFunc("chat "+content, func(m Message) bool {
+			return m.Type == "chat" && m.UserID == c.userID && m.Content == content
+		})
+	}
+}
+
+func TestRandomAndCustomID(t *testing.T) {
+	ts := newTestServer(t)
+
+	random := ts.login(t, "")
+	if !regexp.MustCompile(`^\p{Han}{4}\d{3}$`).MatchString(random.userID) {
+		t.Errorf("随机ID格式不对：%q", random.userID)
+	}
+
+	custom := ts.login(t, "  小明  ")
+	if custom.userID != "小明" {
+		t.Errorf("自定义ID = %q，应去掉首尾空白", custom.userID)
+	}
+}
+
+func TestJoinLeaveBroadcast(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+	bob := ts.login(t, "bob")
+
+	join := alice.expectJoin("bob")
+	if !strings.Contains(join.Content, "bob 加入聊天室") || join.Color == "" {
+		t.Errorf("加入消息不完整：%+v", join)
+	}
+
+	bob.chat("大家好")
+	msg := alice.expect("chat", "大家好")
+	if msg.UserID != "bob" || msg.Time == "" {
+		t.Errorf("聊天消息缺少发送者信息：%+v", msg)
+	}
+
+	bob.chat("/exit")
+	leave := alice.expect("leave", "主动退出聊天室")
+	if leave.UserID != "bob" {
+		t.Errorf("离开消息的用户 = %q", leave.UserID)
+	}
+	bob.expectClosed()
+}
+
+func TestOnlineList(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+	ts.login(t, "bob")
+	alice.expectJoin("bob")
+
+	alice.chat("/online")
+	online := alice.expect("online", "在线用户列表（2人）")
+	for _, id := range []string{"alice", "bob"} {
+		if !strings.Contains(online.Content, id) {
+			t.Errorf("在线列表缺少 %s：\n%s", id, online.Content)
+		}
+	}
+	// 在线列表只显示脱敏后的IP
+	if strings.Contains(online.Content, "127.0.0.1") {
+		t.Errorf("在线列表泄露了完整IP：\n%s", online.Content)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.login(t, "alice")
+
+	c.chat("/help")
+	help := c.expect("help", "可用命令")
+	for _, cmd := range []string{"/online", "/exit", "/color", "/msg", "/close"} {
+		if !strings.Contains(help.Content, cmd) {
+			t.Errorf("帮助信息缺少 %s", cmd)
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.login(t, "alice")
+
+	c.chat("/color")
+	c.expect("color", "你已变色")
+	c.chat("换了颜色")
+	msg := c.expect("chat", "换了颜色")
+	if !slices.Contains(colors, msg.Color) {
+		t.Errorf("颜色 %q 不在预定义颜色列表中", msg.Color)
+	}
+}
+
+func TestCloseSchedule(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+	bob := ts.login(t, "bob")
+
+	alice.chat("/close")
+	alice.expect("system", "服务器未设置关闭时间")
+	alice.chat("/close abc")
+	alice.expect("system", "请输入有效的关闭时间")
+
+	alice.chat("/close 10")
+	bob.expect("system", "服务器将在 10分钟 后关闭（12:10:00）")
+	// 查询会等设置关闭计划的锁释放，收到回复时定时器已全部设置
+	alice.chat("/close")
+	alice.expect("system", "服务器将在 10:00 后关闭")
+
+	ts.clock.Advance(5*time.Minute - 1500*time.Millisecond)
+	bob.chat("/close")
+	bob.expect("system", "服务器将在 05:02 后关闭")
+
+	ts.clock.Advance(1500 * time.Millisecond)
+	bob.expect("system", "服务器将在5分钟后关闭")
+	ts.clock.Advance(4 * time.Minute)
+	bob.expect("system", "服务器将在1分钟后关闭")
+	ts.clock.Advance(30 * time.Second)
+	bob.expect("system", "服务器将在30秒后关闭")
+	ts.clock.Advance(20 * time.Second)
+	bob.expect("system", "服务器将在10秒后关闭")
+
+	ts.clock.Advance(10 * time.Second)
+	alice.expect("system", "服务器已关闭")
+	// 等待最终通知广播完成后才断开连接
+	ts.clock.Advance(shutdownGracePeriod)
+	select {
+	case <-ts.Done():
+	case <-time.After(testReadTimeout):
+		t.Fatal("关闭时间到了，聊天室没有停止")
+	}
+	alice.expectClosed()
+	bob.expectClosed()
+}
+
+func TestCloseReschedule(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.login(t, "alice")
+
+	c.chat("/close 10")
+	c.expect("system", "服务器将在 10分钟 后关闭")
+	c.chat("/close 3")
+	c.expect("system", "服务器将在 3分钟 后关闭")
+	c.chat("/close")
+	c.expect("system", "服务器将在 03:00 后关闭")
+
+	// 旧计划的 5 分钟提醒不会再出现，新计划在 2 分钟后提醒
+	ts.clock.Advance(2 * time.Minute)
+	c.expect("system", "服务器将在1分钟后关闭")
+	if !ts.CancelShutdown() {
+		t.Fatal("应存在可取消的关闭计划")
+	}
+	c.expect("system", "关闭计划已取消")
+	ts.clock.Advance(10 * time.Minute)
+	c.chat("/close")
+	c.expect("system", "服务器未设置关闭时间")
+}
+
+func TestCloseCancelDuringGrace(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.login(t, "alice")
+
+	c.chat("/close 1")
+	c.expect("system", "服务器将在 1分钟 后关闭")
+	ts.waitTimers(t, 2)
+	ts.clock.Advance(time.Minute)
+	c.expect("system", "服务器已关闭")
+
+	// 最终通知之后、断开连接之前取消，聊天室不会停止
+	if !ts.CancelShutdown() {
+		t.Fatal("等待断开期间应能取消关闭计划")
+	}
+	c.expect("system", "关闭计划已取消")
+	ts.clock.Advance(time.Minute)
+	c.chat("还在")
+	c.expect("chat", "还在")
+	if ts.stopped() {
+		t.Error("取消关闭计划后聊天室仍被停止")
+	}
+}
+
+func TestAdminShutdown(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.login(t, "alice")
+
+	for _, body := range []string{
+		`{}`,
+		`{"minutes":-5}`,
+		`{"minutes":1441}`,
+		`{"minutes":153722868}`, // 换算成 time.Duration 会溢出为负数
+		`{"delay":"25h"}`,
+		`{"delay":"abc"}`,
+	} {
+		if code := ts.admin(t, "POST", "/api/admin/shutdown", body); code != http.StatusBadRequest {
+			t.Errorf("关闭时间 %s 返回 %d，应为 400", body, code)
+		}
+	}
+	if _, pending := ts.ShutdownAt(); pending {
+		t.Fatal("无效的关闭时间不应设置关闭计划")
+	}
+
+	if code := ts.admin(t, "POST", "/api/admin/shutdown", `{"minutes":1440}`); code != http.StatusOK {
+		t.Fatalf("设置关闭时间返回 %d", code)
+	}
+	c.expect("system", "服务器将在 24小时 后关闭")
+	if code := ts.admin(t, "DELETE", "/api/admin/shutdown", ""); code != http.StatusNoContent {
+		t.Fatalf("取消关闭计划返回 %d", code)
+	}
+	c.expect("system", "关闭计划已取消")
+}
+
+func TestCloseDurations(t *testing.T) {
+	ts := newTestServer(t)
+	if err := ts.SetShutdownWarnings([]time.Duration{10 * time.Second, time.Minute, 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	c := ts.login(t, "alice")
+
+	for _, tc := range []struct{ arg, want, remaining string }{
+		{"90s", "1分30秒 后关闭（12:01:30）", "01:30"},
+		{"1h30m", "1小时30分钟 后关闭（13:30:00）", "90:00"},
+		{"at 18:00", "6小时 后关闭（18:00:00）", "360:00"},
+		// 今天已过的时刻顺延到明天
+		{"at 11:59:30", "23小时59分30秒 后关闭（11:59:30）", "1439:30"},
+	} {
+		c.chat("/close " + tc.arg)
+		c.expect("system", tc.want)
+		c.chat("/close")
+		c.expect("system", "服务器将在 "+tc.remaining+" 后关闭")
+	}
+
+	for _, tc := range []struct{ arg, want string }{
+		{"0", "请输入有效的关闭时间"},
+		{"-5m", "请输入有效的关闭时间"},
+		{"at 25:00", "请输入有效的关闭时间"},
+		{"1x", "请输入有效的关闭时间"},
+		{"25h", "关闭时间最长为 24 小时"},
+		{"1441", "关闭时间最长为 24 小时"},
+		// 换算成 time.Duration 会溢出：前者变成负数，后者绕回约 26 秒
+		{"153722868", "关闭时间最长为 24 小时"},
+		{"307445735", "关闭时间最长为 24 小时"},
+	} {
+		c.chat("/close " + tc.arg)
+		c.expect("system", tc.want)
+	}
+	// 无效参数不影响已有的关闭计划
+	c.chat("/close")
+	c.expect("system", "服务器将在 1439:30 后关闭")
+
+	// 自定义提醒时间点按从远到近触发，重复的只提醒一次
+	c.chat("/close 2m")
+	c.expect("system", "服务器将在 2分钟 后关闭")
+	ts.clock.Advance(time.Minute)
+	c.expect("system", "服务器将在1分钟后关闭")
+	ts.clock.Advance(50 * time.Second)
+	c.expect("system", "服务器将在10秒后关闭")
+	ts.clock.Advance(10 * time.Second)
+	c.expect("system", "服务器已关闭")
+}
+
+func TestOpenHours(t *testing.T) {
+	ts := newTestServer(t)
+	hours, err := ParseOpenHours("mon-fri 12:00-13:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 测试时钟从周五 12:00 开始，处于开放时段
+	ts.SetOpenHours(hours)
+	alice := ts.login(t, "alice")
+
+	ts.clock.Advance(time.Hour)
+	alice.expect("system", "开放时间即将结束，聊天室将在30分钟后关闭")
+	ts.clock.Advance(30 * time.Minute)
+	alice.expect("system", "聊天室已关闭，下次开放时间：03-04（周一）12:00")
+	ts.clock.Advance(shutdownGracePeriod)
+	alice.expectClosed()
+
+	// 开放时段之外拒绝登录
+	closed := ts.connect(t, nil)
+	closed.send("hello", "")
+	closed.expect("closed", "聊天室当前不在开放时间，下次开放时间：03-04（周一）12:00")
+	closed.expectClosed()
+
+	// 周一 12:00 重新开放
+	ts.clock.Advance(70*time.Hour + 30*time.Minute - shutdownGracePeriod)
+	bob := ts.login(t, "bob")
+	bob.chat("/online")
+	bob.expect("online", "bob")
+}
+
+func TestPanic(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice", featurePanic)
+	bob := ts.login(t, "bob", featurePanic)
+	alice.expectJoin("bob")
+	carol := ts.login(t, "carol")
+
+	bob.chat("/resume")
+	bob.expect("system", "当前不在老板键模式")
+
+	alice.chat("/panic")
+	alice.expect("panic", "alice 按下了老板键")
+	bob.expect("panic", "alice 按下了老板键")
+	// 不支持老板键的客户端只收到系统通知
+	carol.expect("system", "alice 按下了老板键")
+
+	// 老板键模式下不投递聊天和私聊
+	carol.chat("被看到就完了")
+	carol.expect("chat", "被看到就完了")
+	carol.chat("/msg bob 私聊也不行")
+	carol.expect("system", "bob 正处于老板键模式，私聊未送达")
+
+	bob.chat("/panic")
+	bob.expect("system", "老板键刚刚按过，请 30秒 后再试")
+	bob.chat("/resume")
+	bob.expectFunc("resume", func(m Message) bool {
+		if m.Type == "chat" || m.Type == "private" {
+			t.Errorf("老板键模式下收到了消息：%s %q", m.Type, m.Content)
+		}
+		return m.Type == "resume" && strings.Contains(m.Content, "期间有 2 条消息未显示")
+	})
+	carol.chat("老板走了")
+	bob.expect("chat", "老板走了")
+
+	// alice 仍处于老板键模式，冷却结束后可以再次按下
+	ts.clock.Advance(panicCooldown)
+	carol.chat("/panic")
+	alice.expect("panic", "carol 按下了老板键")
+	bob.expect("panic", "carol 按下了老板键")
+	alice.chat("/resume")
+	alice.expect("resume", "期间有 2 条消息未显示")
+}
+
+func TestXSSEscaping(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+	evil := ts.login(t, `<script>alert("id")</script>`)
+
+	const escapedID = `&lt;script&gt;alert(&quot;id&quot;)&lt;/script&gt;`
+	if evil.userID != escapedID {
+		t.Errorf("用户ID没有转义：%q", evil.userID)
+	}
+	alice.expectJoin(escapedID)
+
+	evil.chat(`<img src=x onerror='alert(1)'> & more`)
+	msg := alice.expectFunc("chat", func(m Message) bool { return m.Type == "chat" })
+	if want := `&lt;img src=x onerror=&#039;alert(1)&#039;&gt; &amp; more`; msg.Content != want {
+		t.Errorf("聊天内容 = %q，应为 %q", msg.Content, want)
+	}
+	if msg.UserID != escapedID {
+		t.Errorf("发送者ID = %q", msg.UserID)
+	}
+}
+
+func TestControlCharsRejected(t *testing.T) {
+	ts := newTestServer(t)
+	c := ts.dial(t)
+	c.send("password", testPassword)
+	c.expect("setid", "请输入自定义ID")
+	c.send("setid", "evil\x1b]0;pwned\x07")
+	c.expect("setid", "不能包含控制字符")
+	c.send("setid", "alice")
+	c.expect("welcome", "登录成功")
+
+	c.chat("\x1b]52;c;Y3VybCBldmlsLnNoIHwgc2g=\x07")
+	c.expect("system", "消息不能包含控制字符")
+	c.chat("/msg alice \x1b[2J")
+	c.expect("system", "消息不能包含控制字符")
+}
+
+func TestAbruptDisconnect(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+	bob := ts.login(t, "bob")
+	alice.expectJoin("bob")
+
+	// 登录阶段直接断开：不会广播加入或离开
+	half := ts.dial(t)
+	half.conn.UnderlyingConn().Close()
+
+	// 不发送关闭帧，直接断开底层连接
+	bob.conn.UnderlyingConn().Close()
+	leave := alice.expect("leave", "异常离开聊天室")
+	if leave.UserID != "bob" {
+		t.Errorf("离开消息的用户 = %q", leave.UserID)
+	}
+
+	alice.chat("/online")
+	online := alice.expect("online", "在线用户列表（1人）")
+	if strings.Contains(online.Content, "bob") {
+		t.Errorf("断开的用户仍在在线列表中：\n%s", online.Content)
+	}
+	alice.chat("还有人吗")
+	alice.expect("chat", "还有人吗")
+}
+
+func TestStopReleasesResources(t *testing.T) {
+	ts := newTestServer(t)
+	ts.AddBot(NewEchoBot())
+	c := ts.login(t, "alice")
+	bot := ts.bots[0]
+
+	if err := ts.Stop(context.Background()); err != nil {
+		t.Fatalf("停止聊天室失败：%v", err)
+	}
+	c.expectClosed()
+
+	// 机器人的消息队列已关闭，处理循环随之结束
+	for range bot.inbox {
+	}
+	ts.AddBot(NewDiceBot())
+	if len(ts.bots) != 0 {
+		t.Errorf("停止后仍有 %d 个机器人", len(ts.bots))
+	}
+
+	resp, err := http.Get(ts.httpURL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("停止后 /readyz 返回 %d，应为 503", resp.StatusCode)
+	}
+}
+
+func TestStopClosesHalfOpenConnections(t *testing.T) {
+	ts := newTestServer(t)
+
+	// IRC：连上后还没有注册
+	_, ircLines := dialIRC(t, ts.ChatServer)
+
+	// SSH：完成握手但还没有请求 shell
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	// SSH 握手双方同时先写版本号，不能使用无缓冲的 net.Pipe
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			ts.handleSSHConn(conn, config)
+		}
+	}()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { clientConn.Close() })
+	sconn, _, _, err := ssh.NewClientConn(clientConn, listener.Addr().String(), &ssh.ClientConfig{
+		User:            "alice",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("SSH握手失败：%v", err)
+	}
+	sshClosed := make(chan struct{})
+	go func() {
+		sconn.Wait()
+		close(sshClosed)
+	}()
+
+	// IRC 连接在单独的协程中登记
+	deadline := time.Now().Add(testReadTimeout)
+	for {
+		ts.lifecycleMutex.Lock()
+		tracked := len(ts.conns)
+		ts.lifecycleMutex.Unlock()
+		if tracked == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("登记的连接数 = %d，应为 2", tracked)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testReadTimeout)
+	defer cancel()
+	if err := ts.Stop(ctx); err != nil {
+		t.Fatalf("有未完成注册的连接时 Stop 没有返回：%v", err)
+	}
+	expectIRCClosed(t, ircLines)
+	select {
+	case <-sshClosed:
+	case <-time.After(testReadTimeout):
+		t.Fatal("SSH连接没有被关闭")
+	}
+}
+
+func TestMuteExpires(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+	id := ts.findClientByUserID("alice").ID
+
+	if code := ts.admin(t, "POST", fmt.Sprintf("/api/admin/clients/%d/mute", id), `{"minutes":2}`); code != http.StatusNoContent {
+		t.Fatalf("禁言接口返回 %d", code)
+	}
+	alice.expect("system", "你已被管理员禁言 2 分钟")
+	// 超过一年的时长直接拒绝，不会因为溢出变成立即过期
+	for _, action := range []string{"mute", "ban"} {
+		path := fmt.Sprintf("/api/admin/clients/%d/%s", id, action)
+		if code := ts.admin(t, "POST", path, `{"minutes":153722868}`); code != http.StatusBadRequest {
+			t.Errorf("%s 超大分钟数返回 %d，应为 400", action, code)
+		}
+	}
+	alice.chat("能说话吗")
+	alice.expect("system", "暂时无法发言")
+
+	ts.clock.Advance(2*time.Minute - time.Second)
+	alice.chat("还不行")
+	alice.expect("system", "暂时无法发言")
+
+	ts.clock.Advance(time.Second)
+	alice.chat("可以了")
+	alice.expect("chat", "可以了")
+}
+
+func TestBanUsesRemoteAddr(t *testing.T) {
+	ts := newTestServer(t)
+	ts.login(t, "alice")
+	id := ts.findClientByUserID("alice").ID
+
+	if code := ts.admin(t, "POST", fmt.Sprintf("/api/admin/clients/%d/ban", id), `{"minutes":5}`); code != http.StatusNoContent {
+		t.Fatalf("封禁接口返回 %d", code)
+	}
+	// 未设置可信代理：伪造 X-Forwarded-For 也无法绕过封禁
+	spoofed := ts.connect(t, http.Header{"X-Forwarded-For": {"198.51.100.9"}})
+	spoofed.expect("system", "你已被管理员封禁")
+	spoofed.expectClosed()
+
+	// 过期的封禁在检查时清除
+	ts.clock.Advance(5 * time.Minute)
+	ts.login(t, "alice")
+	ts.clientsMutex.RLock()
+	remaining := len(ts.bans)
+	ts.clientsMutex.RUnlock()
+	if remaining != 0 {
+		t.Errorf("过期的封禁没有清除：%d 条", remaining)
+	}
+}
+
+func TestBanBehindTrustedProxy(t *testing.T) {
+	ts := newTestServer(t)
+	proxies, err := ParseTrustedProxies("127.0.0.0/8, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.SetTrustedProxies(proxies)
+
+	c := ts.connect(t, http.Header{"X-Forwarded-For": {"198.51.100.9"}})
+	c.send("password", testPassword)
+	c.expect("setid", "请输入自定义ID")
+	c.send("setid", "mallory")
+	c.expect("welcome", "登录成功")
+	id := ts.findClientByUserID("mallory").ID
+	if code := ts.admin(t, "POST", fmt.Sprintf("/api/admin/clients/%d/ban", id), `{}`); code != http.StatusNoContent {
+		t.Fatalf("封禁接口返回 %d", code)
+	}
+	c.expectClosed()
+
+	// 经可信代理转发时按转发头中的IP封禁，同一代理后的其他用户不受影响
+	again := ts.connect(t, http.Header{"X-Forwarded-For": {"198.51.100.9"}})
+	again.expect("system", "你已被管理员封禁")
+	ts.login(t, "alice")
+}
+
+func TestRemindBot(t *testing.T) {
+	ts := newTestServer(t)
+	ts.AddBot(NewRemindBot())
+	c := ts.login(t, "alice")
+
+	c.chat("/remind 10m 喝水")
+	c.expect("chat", "10m0s 后提醒你：喝水")
+	ts.waitTimers(t, 1)
+
+	// 时间未到不会提醒：标记消息应先于提醒到达
+	ts.clock.Advance(10*time.Minute - time.Second)
+	c.chat("marker")
+	m := c.expectFunc("chat marker", func(m Message) bool {
+		return m.Type == "chat" && (m.Content == "marker" || strings.Contains(m.Content, "⏰"))
+	})
+	if m.Content != "marker" {
+		t.Fatalf("提醒提前出现：%q", m.Content)
+	}
+
+	ts.clock.Advance(time.Second)
+	c.expect("chat", "⏰ @alice 提醒：喝水")
+}
+
+func TestRemindBotLimitAndStop(t *testing.T) {
+	ts := newTestServer(t)
+	ts.AddBot(NewRemindBot())
+	c := ts.login(t, "alice")
+
+	for i := range maxPendingReminders {
+		c.chat(fmt.Sprintf("/remind %dm 第%d条", i+1, i+1))
+		c.expect("chat", fmt.Sprintf("提醒你：第%d条", i+1))
+	}
+	c.chat("/remind 1m 太多了")
+	c.expect("chat", "已有 5 条提醒在等待")
+
+	// 第一条提醒触发后可以再设置一条
+	ts.clock.Advance(time.Minute)
+	c.expect("chat", "⏰ @alice 提醒：第1条")
+	c.chat("/remind 1h 再来一条")
+	c.expect("chat", "提醒你：再来一条")
+	ts.waitTimers(t, maxPendingReminders)
+
+	// 停止聊天室时取消所有等待中的提醒
+	if err := ts.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := ts.clock.Pending(); n != 0 {
+		t.Errorf("停止后仍有 %d 个提醒定时器", n)
+	}
+}
+
+func TestStaticAssets(t *testing.T) {
+	ts := newTestServer(t)
+	for path, want := range map[string]int{
+		"/":                  http.StatusOK,
+		"/index.html":        http.StatusOK,
+		"/static/index.html": http.StatusNotFound, // 资源以 static 目录为根
+		"/web.go":            http.StatusNotFound,
+	} {
+		resp, err := http.Get(ts.httpURL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s 返回 %d，应为 %d", path, resp.StatusCode, want)
+		}
+		if want == http.StatusOK && !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Errorf("GET %s 的 Content-Type = %q", path, resp.Header.Get("Content-Type"))
+		}
+	}
+}
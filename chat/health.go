@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 广播通道占用超过该比例时视为饱和，/readyz 返回未就绪
+const broadcastSaturation = 0.9
+
+// 归属地查询接口的健康状况（只统计公网IP的查询结果）
+type resolverHealth struct {
+	mu          sync.Mutex
+	lastOutcome string
+	lastChecked time.Time
+}
+
+// 记录一次归属地查询结果
+func (h *resolverHealth) record(outcome string, now time.Time) {
+	if outcome == "local" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastOutcome = outcome
+	h.lastChecked = now
+}
+
+// 归属地查询接口状态
+type resolverStatus struct {
+	Healthy     bool       `json:"healthy"`
+	LastOutcome string     `json:"lastOutcome,omitempty"`
+	LastChecked *time.Time `json:"lastChecked,omitempty"`
+}
+
+// 当前状态：尚未查询过时视为健康
+func (h *resolverHealth) status() resolverStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastChecked.IsZero() {
+		return resolverStatus{Healthy: true}
+	}
+	lastChecked := h.lastChecked
+	return resolverStatus{
+		Healthy:     h.lastOutcome == "ok" || h.lastOutcome == "unknown",
+		LastOutcome: h.lastOutcome,
+		LastChecked: &lastChecked,
+	}
+}
+
+// /status 返回的服务状态
+type serverStatus struct {
+	Version       string         `json:"version"`
+	StartedAt     time.Time      `json:"startedAt"`
+	UptimeSeconds int64          `json:"uptimeSeconds"`
+	Clients       int            `json:"clients"`
+	Bots          int            `json:"bots"`
+	ShutdownAt    *time.Time     `json:"shutdownAt,omitempty"`
+	ClosesAt      *time.Time     `json:"closesAt,omitempty"`   // 设置了开放时段时，本次开放结束的时间
+	NextOpenAt    *time.Time     `json:"nextOpenAt,omitempty"` // 设置了开放时段且当前未开放时，下次开放的时间
+	Resolver      resolverStatus `json:"resolver"`
+}
+
+// 存活探针：进程能响应即为存活
+func (s *ChatServer) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// 就绪探针：已停止、计划关闭中或广播通道饱和时返回 503
+func (s *ChatServer) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.stopped() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "stopped"})
+		return
+	}
+	if _, pending := s.ShutdownAt(); pending {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutdown_pending"})
+		return
+	}
+	if float64(len(s.broadcast)) >= float64(cap(s.broadcast))*broadcastSaturation {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "broadcast_saturated"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// 服务状态：版本、运行时长、在线人数、计划关闭时间、开放时段和归属地接口健康状况
+func (s *ChatServer) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	s.clientsMutex.RLock()
+	status := serverStatus{
+		Version:       Version,
+		StartedAt:     s.startedAt,
+		UptimeSeconds: int64(s.clock.Now().Sub(s.startedAt).Seconds()),
+		Clients:       len(s.clients),
+		Bots:          len(s.bots),
+		Resolver:      s.resolver.status(),
+	}
+	s.clientsMutex.RUnlock()
+	if at, pending := s.ShutdownAt(); pending {
+		status.ShutdownAt = &at
+	}
+	status.ClosesAt, status.NextOpenAt = s.openHoursStatus()
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,283 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 聊天室的开放时段，例如 "mon-fri 12:00-13:30"
+type OpenHours struct {
+	windows []openWindow
+}
+
+// 一个开放时段：在 days 中的每一天，从 start 开放到 end
+type openWindow struct {
+	days       [7]bool       // 按 time.Weekday 索引
+	start, end time.Duration // 当天的时:分，按墙上时间计算（见 atTimeOfDay）
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+var weekdayLabels = [7]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// 解析开放时段配置：多个时段用 ; 分隔，每个时段为 "星期 开始-结束"，
+// 星期可以是 mon,wed、mon-fri 或 daily，例如 "mon-fri 12:00-13:30; sat 10:00-12:00"。
+// 空字符串表示不限制开放时间，返回 nil
+func ParseOpenHours(spec string) (*OpenHours, error) {
+	var h OpenHours
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, err := parseOpenWindow(part)
+		if err != nil {
+			return nil, fmt.Errorf("开放时段 %q 格式错误：%w", part, err)
+		}
+		h.windows = append(h.windows, w)
+	}
+	if len(h.windows) == 0 {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+func parseOpenWindow(s string) (openWindow, error) {
+	var w openWindow
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return w, fmt.Errorf("应为 \"星期 开始-结束\"，例如 mon-fri 12:00-13:30")
+	}
+	if err := parseWeekdays(strings.ToLower(fields[0]), &w.days); err != nil {
+		return w, err
+	}
+	startStr, endStr, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return w, fmt.Errorf("时间应为 开始-结束，例如 12:00-13:30")
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(startStr); err != nil {
+		return w, err
+	}
+	if w.end, err = parseTimeOfDay(endStr); err != nil {
+		return w, err
+	}
+	if w.start >= w.end {
+		return w, fmt.Errorf("结束时间必须晚于开始时间（不支持跨零点，可拆成两个时段）")
+	}
+	return w, nil
+}
+
+// 解析 mon,wed、mon-fri、daily 形式的星期
+func parseWeekdays(s string, days *[7]bool) error {
+	if s == "daily" || s == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, ok := weekdayNames[from]
+		if !ok {
+			return fmt.Errorf("未知的星期 %q，应为 mon/tue/wed/thu/fri/sat/sun", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[to]; !ok {
+				return fmt.Errorf("未知的星期 %q，应为 mon/tue/wed/thu/fri/sat/sun", to)
+			}
+		}
+		// 支持 fri-mon 这样跨周末的范围
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// 解析 时:分，24:00 表示当天结束
+func parseTimeOfDay(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("时间 %q 应为 时:分，例如 13:30", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// t 当天的零点
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// day 当天的时:分 d（24:00 为次日零点）。按日期和钟点构造而不是从零点累加时长，
+// 夏令时切换的当天一天不是 24 小时，累加会偏差一小时
+func atTimeOfDay(day time.Time, d time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// t 所在的开放时段的结束时间，以及 t 是否处于开放时段
+func (h *OpenHours) windowEnd(t time.Time) (time.Time, bool) {
+	for _, w := range h.windows {
+		if !w.days[t.Weekday()] {
+			continue
+		}
+		if end := atTimeOfDay(t, w.end); !t.Before(atTimeOfDay(t, w.start)) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 当前开放时段结束、聊天室关闭的时间；首尾相接的时段视为连续开放（最多向后看一周）
+func (h *OpenHours) closesAt(t time.Time) (time.Time, bool) {
+	end, open := h.windowEnd(t)
+	if !open {
+		return time.Time{}, false
+	}
+	limit := t.AddDate(0, 0, 7)
+	for end.Before(limit) {
+		next, ok := h.windowEnd(end)
+		if !ok {
+			break
+		}
+		end = next
+	}
+	return end, true
+}
+
+// t 之后的下一个开放时间
+func (h *OpenHours) nextOpen(t time.Time) time.Time {
+	day := startOfDay(t)
+	var next time.Time
+	for offset := 0; offset <= 7 && next.IsZero(); offset++ {
+		date := day.AddDate(0, 0, offset)
+		for _, w := range h.windows {
+			start := atTimeOfDay(date, w.start)
+			if w.days[date.Weekday()] && start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// 开放时间的中文写法：03-04（周一）12:00
+func formatOpenTime(t time.Time) string {
+	return fmt.Sprintf("%s（%s）%s", t.Format("01-02"), weekdayLabels[t.Weekday()], t.Format("15:04"))
+}
+
+// 设置开放时段（为空表示不限制），立即按当前时间安排开放和关闭；
+// 开放时段结束时按关闭提醒时间点提前提醒，到点后断开所有连接，直到下次开放
+func (s *ChatServer) SetOpenHours(h *OpenHours) {
+	s.hoursMutex.Lock()
+	defer s.hoursMutex.Unlock()
+	s.openHours = h
+	s.planOpenHoursLocked()
+}
+
+// 聊天室是否处于开放时段之外，以及下次开放的时间
+func (s *ChatServer) closedUntil() (time.Time, bool) {
+	s.hoursMutex.Lock()
+	defer s.hoursMutex.Unlock()
+	if s.openHours == nil {
+		return time.Time{}, false
+	}
+	now := s.clock.Now()
+	if _, open := s.openHours.windowEnd(now); open {
+		return time.Time{}, false
+	}
+	return s.openHours.nextOpen(now), true
+}
+
+// 停止开放时段相关的定时器（调用方需持有 hoursMutex）
+func (s *ChatServer) clearOpenHoursLocked() {
+	for _, timer := range s.hoursTimers {
+		timer.Stop()
+	}
+	s.hoursTimers = nil
+	s.hoursGen++
+}
+
+// 为当前开放时段安排设置一个定时器（调用方需持有 hoursMutex），
+// 回调持有 hoursMutex 执行，返回的通知（不为空时）在释放锁之后广播；
+// 开放时段重新设置或聊天室停止后跳过
+func (s *ChatServer) addOpenHoursTimerLocked(d time.Duration, f func() string) {
+	gen := s.hoursGen
+	timer := s.clock.AfterFunc(d, func() {
+		s.hoursMutex.Lock()
+		var notice string
+		if s.hoursGen == gen {
+			notice = f()
+		}
+		s.hoursMutex.Unlock()
+		if notice != "" {
+			s.notifyShutdown(notice)
+		}
+	})
+	s.hoursTimers = append(s.hoursTimers, timer)
+}
+
+// 按当前时间安排下一次关闭（开放中）或下一次开放（已关闭）（调用方需持有 hoursMutex）
+func (s *ChatServer) planOpenHoursLocked() {
+	s.clearOpenHoursLocked()
+	if s.openHours == nil || s.stopped() {
+		return
+	}
+	now := s.clock.Now()
+	end, open := s.openHours.closesAt(now)
+	if !open {
+		next := s.openHours.nextOpen(now)
+		s.logger.Info("聊天室不在开放时段", "event", "hours_closed", "next_open", next)
+		s.addOpenHoursTimerLocked(next.Sub(now), func() string {
+			s.logger.Info("聊天室开放", "event", "hours_open")
+			s.planOpenHoursLocked()
+			return ""
+		})
+		return
+	}
+
+	// 沿用关闭服务器的提醒时间点
+	s.shutdownMutex.Lock()
+	warnings := s.shutdownWarnings
+	s.shutdownMutex.Unlock()
+
+	d := end.Sub(now)
+	s.scheduleWarnings(d, warnings, "【系统通知】开放时间即将结束，聊天室将在%s后关闭，请做好准备！", s.addOpenHoursTimerLocked)
+	s.addOpenHoursTimerLocked(d, func() string {
+		next := s.openHours.nextOpen(end)
+		// 等待关闭消息广播完成后断开所有连接，再安排下次开放
+		s.addOpenHoursTimerLocked(shutdownGracePeriod, func() string {
+			s.logger.Info("开放时间结束，断开所有连接", "event", "hours_end", "next_open", next)
+			s.closeSessions()
+			s.planOpenHoursLocked()
+			return ""
+		})
+		return fmt.Sprintf("【系统通知】开放时间结束，聊天室已关闭，下次开放时间：%s", formatOpenTime(next))
+	})
+}
+
+// /status 中的开放时段信息：开放中返回本次关闭时间，未开放返回下次开放时间
+func (s *ChatServer) openHoursStatus() (closesAt, nextOpenAt *time.Time) {
+	s.hoursMutex.Lock()
+	defer s.hoursMutex.Unlock()
+	if s.openHours == nil {
+		return nil, nil
+	}
+	now := s.clock.Now()
+	if end, open := s.openHours.closesAt(now); open {
+		return &end, nil
+	}
+	next := s.openHours.nextOpen(now)
+	return nil, &next
+}
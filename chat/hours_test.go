@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOpenHours(t *testing.T) {
+	for _, spec := range []string{
+		"mon-fri 12:00",
+		"mon-fri 13:30-12:00",
+		"someday 12:00-13:00",
+		"mon 25:00-26:00",
+		"mon,",
+	} {
+		if _, err := ParseOpenHours(spec); err == nil {
+			t.Errorf("ParseOpenHours(%q) 应返回错误", spec)
+		}
+	}
+	if h, err := ParseOpenHours(" ; "); h != nil || err != nil {
+		t.Errorf("空配置应表示不限制开放时间，得到 %v, %v", h, err)
+	}
+}
+
+func TestOpenHoursWindows(t *testing.T) {
+	h, err := ParseOpenHours("mon-fri 12:00-13:30; fri 13:30-24:00; sat 00:00-01:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, clock string) time.Time {
+		v, _ := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, time.UTC)
+		return v
+	}
+	// 2024-03-04 为周一
+	for _, tc := range []struct {
+		now      time.Time
+		open     bool
+		closesAt time.Time // 开放中：本次关闭时间
+		nextOpen time.Time // 未开放：下次开放时间
+	}{
+		{now: at("2024-03-04", "11:59"), nextOpen: at("2024-03-04", "12:00")},
+		{now: at("2024-03-04", "12:00"), open: true, closesAt: at("2024-03-04", "13:30")},
+		{now: at("2024-03-04", "13:30"), nextOpen: at("2024-03-05", "12:00")},
+		// 周五的时段与周六凌晨首尾相接，连续开放到周六 01:00
+		{now: at("2024-03-08", "12:30"), open: true, closesAt: at("2024-03-09", "01:00")},
+		{now: at("2024-03-09", "01:00"), nextOpen: at("2024-03-11", "12:00")},
+	} {
+		end, open := h.closesAt(tc.now)
+		if open != tc.open || (open && !end.Equal(tc.closesAt)) {
+			t.Errorf("%s：closesAt = %s, %v，期望 %s, %v", tc.now, end, open, tc.closesAt, tc.open)
+		}
+		if !open {
+			if next := h.nextOpen(tc.now); !next.Equal(tc.nextOpen) {
+				t.Errorf("%s：nextOpen = %s，期望 %s", tc.now, next, tc.nextOpen)
+			}
+		}
+	}
+}
+
+// 夏令时切换当天一天不是 24 小时，开放时间仍按墙上时间计算
+func TestOpenHoursDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("缺少时区数据：%v", err)
+	}
+	h, err := ParseOpenHours("sun 09:00-17:00; sun 17:00-24:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, clock string) time.Time {
+		v, _ := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, loc)
+		return v
+	}
+	// 2024-03-10 开始夏令时（当天 23 小时），2024-11-03 结束（当天 25 小时），均为周日
+	for _, day := range []string{"2024-03-10", "2024-11-03"} {
+		if next := h.nextOpen(at(day, "08:00")); !next.Equal(at(day, "09:00")) {
+			t.Errorf("%s：nextOpen = %s，期望 09:00", day, next)
+		}
+		if _, open := h.closesAt(at(day, "08:59")); open {
+			t.Errorf("%s 08:59 不应处于开放时段", day)
+		}
+		// 两个时段首尾相接，连续开放到次日零点
+		end, open := h.closesAt(at(day, "09:00"))
+		if want := at(day, "00:00").AddDate(0, 0, 1); !open || !end.Equal(want) {
+			t.Errorf("%s：closesAt = %s, %v，期望 %s", day, end, open, want)
+		}
+	}
+}
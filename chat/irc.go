@@ -0,0 +1,491 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"demogo/termui"
+	"demogo/transport"
+)
+
+// IRC 网关：irssi、weechat 等 IRC 客户端可以直接连入聊天室
+// PASS 对应聊天室密码，NICK 对应用户ID；聊天室只有一个房间，对应频道 #lobby
+// PRIVMSG #lobby 为群聊，PRIVMSG 昵称 为私聊（/msg）
+
+const (
+	ircServerName  = "chatroom"
+	ircChannel     = "#" + defaultRoom
+	ircMaxLineSize = 8 << 10 // 单行输入的最大字节数
+	ircMaxText     = 400     // 单行输出中消息内容的最大字节数，超出后拆成多行
+)
+
+// 连接后完成 NICK/USER 注册的时限（测试中会缩短）
+var ircRegistrationTimeout = 30 * time.Second
+
+// IRC 数字应答
+const (
+	rplWelcome        = "001"
+	rplYourHost       = "002"
+	rplCreated        = "003"
+	rplMyInfo         = "004"
+	rplISupport       = "005"
+	rplUModeIs        = "221"
+	rplEndOfWho       = "315"
+	rplChannelModeIs  = "324"
+	rplTopic          = "332"
+	rplNamReply       = "353"
+	rplEndOfNames     = "366"
+	rplMOTD           = "372"
+	rplMOTDStart      = "375"
+	rplEndOfMOTD      = "376"
+	errNoSuchNick     = "401"
+	errNoSuchChannel  = "403"
+	errUnknownCommand = "421"
+	errErroneousNick  = "432"
+	errNicknameInUse  = "433"
+	errNotOnChannel   = "442"
+	errNotRegistered  = "451"
+	errNeedMoreParams = "461"
+	errPasswdMismatch = "464"
+)
+
+var errIRCAuth = errors.New("IRC密码错误")
+
+// 昵称中不能出现的字符，用户ID中的这些字符会替换为下划线
+var ircNickReplacer = strings.NewReplacer(" ", "_", "!", "_", "@", "_", ":", "_", ",", "_", "*", "_")
+
+// 把用户ID转换为IRC昵称
+func ircNick(userID string) string {
+	return ircNickReplacer.Replace(termui.StripControl(html.UnescapeString(userID)))
+}
+
+// 一行IRC消息中不能出现的字符：CR/LF/NUL 会提前结束这一行，后面的内容会被当作新的协议行
+var ircLineBreaker = strings.NewReplacer("\r", " ", "\n", " ", "\x00", " ")
+
+// 聊天室命令：IRC 客户端会把不认识的 /命令 原样发给服务端（如 /online 发送 ONLINE）
+var ircChatCommands = map[string]bool{
+	"ONLINE": true, "COLOR": true, "CLOSE": true, "ECHO": true,
+	"REMIND": true, "ROLL": true, "FLIP": true, "PANIC": true, "RESUME": true,
+}
+
+// 启动IRC网关（阻塞，Stop 后返回 nil）
+func (s *ChatServer) ListenIRC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if err := s.addListener(listener); err != nil {
+		return err
+	}
+	s.logger.Info("IRC网关已启动", "event", "irc_listen", "addr", addr, "channel", ircChannel)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return s.acceptError(err)
+		}
+		go s.handleIRCConn(conn)
+	}
+}
+
+// 处理一个IRC连接：先完成 PASS/NICK/USER 注册，再进入与网页相同的登录流程
+func (s *ChatServer) handleIRCConn(nConn net.Conn) {
+	if !s.trackConn(nConn) {
+		return
+	}
+	defer s.untrackConn(nConn)
+	ip, _, err := net.SplitHostPort(nConn.RemoteAddr().String())
+	if err != nil {
+		ip = nConn.RemoteAddr().String()
+	}
+	client := s.newClient(ip)
+	conn := &transport.CountingConn{Conn: nConn, BytesRead: &client.WireBytesIn, BytesWritten: &client.WireBytesOut}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 512), ircMaxLineSize)
+	transport := &ircTransport{server: s, conn: conn, scanner: scanner, nick: "*"}
+	// 注册阶段限时，连上后一直不注册的连接不会永远占用协程和连接
+	conn.SetReadDeadline(time.Now().Add(ircRegistrationTimeout))
+	if !transport.register() {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	client.transport = transport
+	client.codec = defaultCodec
+	client.Protocol = ProtocolV1 // IRC 命令由网关转换，按旧版协议只取内容
+	client.noEcho = true         // 自己发出的消息 IRC 客户端已经显示
+	s.logger.Debug("IRC会话建立", append(s.logAttrs("irc_open", "", client.IP), "nick", transport.nick)...)
+	s.serveClient(client)
+	transport.sendLine("ERROR :Closing Link: " + transport.nick)
+}
+
+// IRC 传输：把 IRC 命令转换为聊天室消息，把聊天室消息转换为 IRC 消息
+type ircTransport struct {
+	server   *ChatServer
+	conn     net.Conn
+	scanner  *bufio.Scanner
+	mu       sync.Mutex // 串行写连接（读取协程中的应答和广播可能并发）
+	state    terminalState
+	pass     string
+	nick     string
+	user     string
+	userID   string // 聊天室中的用户ID（昵称经 HTML 转义）
+	passUsed bool   // 已把 PASS 交给登录流程（只在读取协程中访问）
+}
+
+func (t *ircTransport) Name() string { return "irc" }
+
+func (t *ircTransport) sendLine(line string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.conn.Write([]byte(ircLineBreaker.Replace(line) + "\r\n"))
+	return err
+}
+
+// 发送服务器数字应答
+func (t *ircTransport) reply(code string, params string) error {
+	return t.sendLine(fmt.Sprintf(":%s %s %s %s", ircServerName, code, t.nick, params))
+}
+
+// 发送一条可能包含多行、超长的文本（PRIVMSG/NOTICE），按行和长度拆分；
+// 去掉控制字符（CR、NUL、CTCP 的 \x01、颜色代码等），防止伪造协议行和 CTCP 请求
+func (t *ircTransport) sendText(prefix, command, target, text string) error {
+	for _, line := range strings.Split(termui.StripControl(text), "\n") {
+		for _, chunk := range splitIRCText(line) {
+			if err := t.sendLine(fmt.Sprintf(":%s %s %s :%s", prefix, command, target, chunk)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 读取并解析一行IRC命令
+func (t *ircTransport) next() (string, []string, bool) {
+	for t.scanner.Scan() {
+		command, params := parseIRCLine(t.scanner.Text())
+		if command != "" {
+			return command, params, true
+		}
+	}
+	return "", nil, false
+}
+
+// 注册阶段：收集 PASS/NICK/USER，连接断开或 QUIT 时返回 false
+func (t *ircTransport) register() bool {
+	for t.userID == "" || t.user == "" {
+		command, params, ok := t.next()
+		if !ok {
+			return false
+		}
+		switch command {
+		case "CAP":
+			if len(params) > 0 && strings.ToUpper(params[0]) == "LS" {
+				t.sendLine(fmt.Sprintf(":%s CAP * LS :", ircServerName))
+			}
+		case "PASS":
+			if len(params) > 0 {
+				t.pass = params[0]
+			}
+		case "NICK":
+			if len(params) == 0 || params[0] == "" {
+				t.reply(errNeedMoreParams, "NICK :缺少昵称")
+				continue
+			}
+			nick := params[0]
+			if ircNick(nick) != nick || strings.HasPrefix(nick, "#") || utf8.RuneCountInString(nick) > 30 {
+				t.reply(errErroneousNick, nick+" :昵称不能包含空格和 !@:,* 等字符，也不能以 # 开头")
+				continue
+			}
+			if t.server.findClientByUserID(nick) != nil {
+				t.reply(errNicknameInUse, nick+" :昵称已被使用")
+				continue
+			}
+			t.nick = nick
+			t.userID = escapeHTML(nick)
+		case "USER":
+			if len(params) == 0 {
+				t.reply(errNeedMoreParams, "USER :缺少参数")
+				continue
+			}
+			t.user = params[0]
+		case "PING":
+			t.pong(params)
+		case "QUIT":
+			return false
+		default:
+			t.reply(errNotRegistered, ":请先完成注册（PASS/NICK/USER）")
+		}
+	}
+	return true
+}
+
+func (t *ircTransport) pong(params []string) {
+	token := ircServerName
+	if len(params) > 0 {
+		token = params[0]
+	}
+	t.sendLine(fmt.Sprintf(":%s PONG %s :%s", ircServerName, ircServerName, token))
+}
+
+// 把内容编码为聊天室消息
+func ircContent(content string) ([]byte, error) {
+	return json.Marshal(Message{Content: content})
+}
+
+// 登录阶段依次交出 PASS 和 NICK；登录后把 IRC 命令转换为聊天内容，网关自己能应答的命令不交给会话
+func (t *ircTransport) Read() ([]byte, error) {
+	if !t.state.loggedIn.Load() {
+		if t.state.password.Load() {
+			// 再次要求密码说明 PASS 错误或为空，IRC 客户端无法重新输入，直接断开
+			if t.passUsed {
+				t.reply(errPasswdMismatch, ":密码错误（请在客户端设置服务器密码）")
+				return nil, errIRCAuth
+			}
+			t.passUsed = true
+			return ircContent(t.pass)
+		}
+		return ircContent(t.nick)
+	}
+
+	for {
+		command, params, ok := t.next()
+		if !ok {
+			if err := t.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		switch command {
+		case "PRIVMSG":
+			if len(params) < 2 {
+				t.reply(errNeedMoreParams, "PRIVMSG :缺少参数")
+				continue
+			}
+			text, ok := ircMessageText(params[1])
+			if !ok {
+				continue
+			}
+			target := params[0]
+			if strings.EqualFold(target, ircChannel) {
+				return ircContent(text)
+			}
+			if to := t.findNick(target); to != "" {
+				return ircContent("/msg " + to + " " + text)
+			}
+			if strings.HasPrefix(target, "#") {
+				t.reply(errNoSuchChannel, target+" :只有 "+ircChannel+" 频道")
+			} else {
+				t.reply(errNoSuchNick, target+" :用户不在线")
+			}
+		case "JOIN":
+			if len(params) == 0 {
+				t.reply(errNeedMoreParams, "JOIN :缺少参数")
+				continue
+			}
+			for _, channel := range strings.Split(params[0], ",") {
+				if strings.EqualFold(channel, ircChannel) {
+					t.sendNames() // 登录后已自动加入
+				} else {
+					t.reply(errNoSuchChannel, channel+" :只有 "+ircChannel+" 频道")
+				}
+			}
+		case "PART":
+			if len(params) > 0 && !strings.EqualFold(params[0], ircChannel) {
+				t.reply(errNotOnChannel, params[0]+" :不在该频道")
+				continue
+			}
+			// 离开唯一的频道即离开聊天室
+			return ircContent("/exit")
+		case "QUIT":
+			return ircContent("/exit")
+		case "NAMES":
+			t.sendNames()
+		case "PING":
+			t.pong(params)
+		case "PONG", "NOTICE", "CAP", "USERHOST", "ISON":
+			// 忽略
+		case "WHO":
+			target := ircChannel
+			if len(params) > 0 {
+				target = params[0]
+			}
+			t.reply(rplEndOfWho, target+" :End of /WHO list.")
+		case "MODE":
+			if len(params) > 0 && strings.EqualFold(params[0], ircChannel) {
+				t.reply(rplChannelModeIs, ircChannel+" +nt")
+			} else if len(params) > 0 && params[0] == t.nick {
+				t.reply(rplUModeIs, "+")
+			}
+		case "NICK":
+			t.sendText(ircServerName, "NOTICE", t.nick, "不支持修改昵称，请重新连接")
+		default:
+			// 聊天室命令，如 /online、/roll 2d6
+			if ircChatCommands[command] {
+				return ircContent(strings.TrimSpace("/" + strings.ToLower(command) + " " + strings.Join(params, " ")))
+			}
+			t.reply(errUnknownCommand, command+" :未知命令")
+		}
+	}
+}
+
+// 按IRC昵称查找在线用户，返回聊天室中的用户ID
+func (t *ircTransport) findNick(nick string) string {
+	t.server.clientsMutex.RLock()
+	defer t.server.clientsMutex.RUnlock()
+	for _, c := range t.server.clients {
+		if strings.EqualFold(ircNick(c.UserID), nick) {
+			return c.UserID
+		}
+	}
+	return ""
+}
+
+// 发送频道成员列表（含机器人）
+func (t *ircTransport) sendNames() {
+	var nicks []string
+	t.server.clientsMutex.RLock()
+	for _, c := range t.server.clients {
+		nicks = append(nicks, ircNick(c.UserID))
+	}
+	// 机器人可以随时加入（AddBot），同样在锁内读取
+	for _, bc := range t.server.bots {
+		nicks = append(nicks, ircNick(bc.Client.UserID))
+	}
+	t.server.clientsMutex.RUnlock()
+
+	line := ""
+	for _, nick := range nicks {
+		if line != "" && len(line)+len(nick) > ircMaxText {
+			t.reply(rplNamReply, "= "+ircChannel+" :"+line)
+			line = ""
+		}
+		line = strings.TrimSpace(line + " " + nick)
+	}
+	if line != "" {
+		t.reply(rplNamReply, "= "+ircChannel+" :"+line)
+	}
+	t.reply(rplEndOfNames, ircChannel+" :End of /NAMES list.")
+}
+
+// 登录成功：发送注册完成应答，把欢迎信息作为 MOTD，并自动加入频道
+func (t *ircTransport) sendWelcome(content string) {
+	t.reply(rplWelcome, ":欢迎来到终端聊天室 "+t.nick)
+	t.reply(rplYourHost, fmt.Sprintf(":Your host is %s, running version %s", ircServerName, Version))
+	t.reply(rplCreated, ":This server was created "+t.server.startedAt.Format("2006-01-02 15:04:05"))
+	t.reply(rplMyInfo, fmt.Sprintf("%s %s o nt", ircServerName, Version))
+	t.reply(rplISupport, "CHANTYPES=# PREFIX=() NETWORK="+ircServerName+" CASEMAPPING=ascii :are supported by this server")
+	t.reply(rplMOTDStart, ":- "+ircServerName+" Message of the Day -")
+	for _, line := range strings.Split(termui.StripControl(html.UnescapeString(content)), "\n") {
+		t.reply(rplMOTD, ":- "+line)
+	}
+	t.reply(rplEndOfMOTD, ":End of /MOTD command.")
+	t.sendLine(fmt.Sprintf(":%s!%s@%s JOIN %s", t.nick, t.user, ircServerName, ircChannel))
+	t.reply(rplTopic, ircChannel+" :终端聊天室 "+Version)
+	t.sendNames()
+}
+
+// 聊天室消息中发送者的IRC前缀
+func ircPrefix(msg Message) string {
+	host := msg.IP
+	if host == "" {
+		host = ircServerName
+	}
+	return fmt.Sprintf("%s!chat@%s", ircNick(msg.UserID), strings.ReplaceAll(host, "*", "x"))
+}
+
+// 会话固定使用JSON编码；自己发出的消息由 fanOut 按会话过滤（见 Client.noEcho），不会写到这里
+func (t *ircTransport) Write(data []byte) error {
+	msg, err := t.state.observe(data)
+	if err != nil {
+		return err
+	}
+	content := html.UnescapeString(msg.Content)
+	switch msg.Type {
+	case "hello", "password", "setid":
+		// 登录流程由 PASS/NICK 自动完成
+		return nil
+	case "welcome":
+		t.sendWelcome(msg.Content)
+		return nil
+	case "chat":
+		return t.sendText(ircPrefix(msg), "PRIVMSG", ircChannel, content)
+	case "private":
+		return t.sendText(ircPrefix(msg), "PRIVMSG", t.nick, content)
+	case "join":
+		return t.sendLine(fmt.Sprintf(":%s JOIN %s", ircPrefix(msg), ircChannel))
+	case "leave":
+		return t.sendLine(fmt.Sprintf(":%s QUIT :离开聊天室", ircPrefix(msg)))
+	default:
+		// 系统通知、在线列表、帮助等
+		return t.sendText(ircServerName, "NOTICE", t.nick, content)
+	}
+}
+
+func (t *ircTransport) WritePrepared(p *transport.Prepared) error { return t.Write(p.Data) }
+
+func (t *ircTransport) Close() error { return t.conn.Close() }
+
+// 解析一行IRC消息，返回大写的命令和参数（忽略前缀，最后一个以 : 开头的参数可以包含空格）
+func parseIRCLine(line string) (string, []string) {
+	line = strings.TrimRight(line, "\r")
+	if strings.HasPrefix(line, ":") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	var params []string
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, ":") && len(params) > 0 {
+			params = append(params, line[1:])
+			break
+		}
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		return "", nil
+	}
+	return strings.ToUpper(params[0]), params[1:]
+}
+
+// 处理 CTCP：ACTION（/me）转换为普通文本，其他 CTCP 请求忽略
+func ircMessageText(text string) (string, bool) {
+	if !strings.HasPrefix(text, "\x01") {
+		return text, text != ""
+	}
+	ctcp := strings.Trim(text, "\x01")
+	if action, ok := strings.CutPrefix(ctcp, "ACTION "); ok {
+		return "* " + action, true
+	}
+	return "", false
+}
+
+// 按字节数拆分过长的文本，不截断多字节字符
+func splitIRCText(text string) []string {
+	if len(text) <= ircMaxText {
+		return []string{text}
+	}
+	var chunks []string
+	for len(text) > ircMaxText {
+		cut := ircMaxText
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	return append(chunks, text)
+}
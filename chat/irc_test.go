@@ -0,0 +1,181 @@
+package chat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 只记录写入内容的连接
+type recordConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) { return c.buf.Write(p) }
+
+func newRecordIRCTransport(nick string) (*ircTransport, *recordConn) {
+	conn := &recordConn{}
+	return &ircTransport{conn: conn, nick: nick, userID: nick}, conn
+}
+
+func writeIRCMessage(t *testing.T, tr *ircTransport, msg Message) {
+	t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIRCStripsLineBreakingBytes(t *testing.T) {
+	tr, conn := newRecordIRCTransport("bob")
+	writeIRCMessage(t, tr, Message{
+		Type:    "chat",
+		UserID:  "evil\r:x",
+		IP:      "10:0:*",
+		Content: "hi\r:evil!x@y PRIVMSG #lobby :pwned\x00:evil2 NOTICE bob :x\x01VERSION\x01\x03" + "4red",
+	})
+	writeIRCMessage(t, tr, Message{Type: "system", Content: "通知\r:evil QUIT :x"})
+
+	lines := strings.Split(strings.TrimSuffix(conn.buf.String(), "\r\n"), "\r\n")
+	if len(lines) != 2 {
+		t.Fatalf("应输出 2 行，实际 %d 行：%q", len(lines), conn.buf.String())
+	}
+	for _, line := range lines {
+		if strings.ContainsAny(line, "\r\n\x00\x01\x03") {
+			t.Errorf("输出行中含有控制字符：%q", line)
+		}
+	}
+	if !strings.HasPrefix(lines[0], ":evil_x!chat@10:0:x PRIVMSG #lobby :hi") {
+		t.Errorf("群聊消息格式不对：%q", lines[0])
+	}
+}
+
+func TestIRCEchoSuppressedBySession(t *testing.T) {
+	s := NewChatServer(testPassword)
+	tr, conn := newRecordIRCTransport("alice")
+	irc := s.newClient("10.0.0.1")
+	irc.UserID, irc.codec, irc.transport, irc.noEcho = "alice", defaultCodec, tr, true
+	// 网页用户后来也取了同样的ID
+	web := s.newClient("10.0.0.2")
+	web.UserID = "alice"
+
+	s.fanOut(Message{Type: "chat", UserID: "alice", Content: "自己发的", Session: irc.ID}, []*Client{irc})
+	s.fanOut(Message{Type: "chat", UserID: "alice", Content: "同名用户发的", Session: web.ID}, []*Client{irc})
+	s.fanOut(Message{Type: "join", UserID: "alice", Session: web.ID}, []*Client{irc})
+
+	out := conn.buf.String()
+	if strings.Contains(out, "自己发的") {
+		t.Errorf("自己发出的消息被回显：%q", out)
+	}
+	if !strings.Contains(out, "PRIVMSG #lobby :同名用户发的") || !strings.Contains(out, " JOIN #lobby") {
+		t.Errorf("同名用户的消息被丢弃：%q", out)
+	}
+}
+
+func TestIRCNamesWhileAddingBots(t *testing.T) {
+	s := NewChatServer(testPassword)
+	tr, conn := newRecordIRCTransport("alice")
+	tr.server = s
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 20 {
+			s.AddBot(NewEchoBot())
+		}
+	}()
+	for range 20 {
+		tr.sendNames()
+	}
+	<-done
+	tr.sendNames()
+	if !strings.Contains(conn.buf.String(), "复读机") {
+		t.Errorf("成员列表中没有机器人：%q", conn.buf.String())
+	}
+}
+
+// 通过内存管道连接IRC网关，返回客户端一端和服务端发来的行（连接关闭后通道关闭）
+func dialIRC(t *testing.T, s *ChatServer) (net.Conn, <-chan string) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go s.handleIRCConn(server)
+	lines := make(chan string, 64)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(client)
+		for scanner.Scan() {
+			lines <- strings.TrimSuffix(scanner.Text(), "\r")
+		}
+	}()
+	return client, lines
+}
+
+// 读取服务端发来的行，直到出现包含 contains 的行
+func expectIRCLine(t *testing.T, lines <-chan string, contains string) {
+	t.Helper()
+	timeout := time.After(testReadTimeout)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("等待 %q 时连接已关闭", contains)
+			}
+			if strings.Contains(line, contains) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("等待 %q 超时", contains)
+		}
+	}
+}
+
+// 服务端应关闭连接
+func expectIRCClosed(t *testing.T, lines <-chan string) {
+	t.Helper()
+	timeout := time.After(testReadTimeout)
+	for {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("连接没有被关闭")
+		}
+	}
+}
+
+func TestIRCRegistrationTimeout(t *testing.T) {
+	old := ircRegistrationTimeout
+	ircRegistrationTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { ircRegistrationTimeout = old })
+	ts := newTestServer(t)
+
+	// 连上后不注册：超时后断开
+	_, idle := dialIRC(t, ts.ChatServer)
+	expectIRCClosed(t, idle)
+
+	// 注册完成后取消时限，空闲超过注册时限也不会断开
+	conn, lines := dialIRC(t, ts.ChatServer)
+	io.WriteString(conn, "PASS "+testPassword+"\r\nNICK bob\r\nUSER bob 0 * :bob\r\n")
+	expectIRCLine(t, lines, "JOIN "+ircChannel)
+	time.Sleep(3 * ircRegistrationTimeout)
+	io.WriteString(conn, "PING check\r\n")
+	expectIRCLine(t, lines, "PONG")
+
+	ts.lifecycleMutex.Lock()
+	tracked := len(ts.conns)
+	ts.lifecycleMutex.Unlock()
+	if tracked != 1 {
+		t.Errorf("登记的连接数 = %d，超时断开的连接应已移除", tracked)
+	}
+}
@@ -0,0 +1,170 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// 聊天室已停止，不再接受新的连接
+var ErrServerStopped = errors.New("聊天室已停止")
+
+// 启动广播协程，需在接受连接前调用；重复调用无效
+func (s *ChatServer) Start() {
+	s.startOnce.Do(func() {
+		go s.Broadcaster()
+	})
+}
+
+// 停止聊天室：关闭 SSH/TCP/IRC 监听、取消关闭计划和开放时段安排、断开所有会话
+// （含登录阶段，以及尚未开始会话的 IRC 注册、SSH 握手阶段的连接）、停止机器人和Webhook投递，
+// 等待会话和连接全部结束，ctx 超时则返回 ctx 的错误。HTTP 服务由调用方关闭
+func (s *ChatServer) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+
+	s.lifecycleMutex.Lock()
+	listeners := s.listeners
+	s.listeners = nil
+	s.lifecycleMutex.Unlock()
+	for _, l := range listeners {
+		l.Close()
+	}
+
+	s.shutdownMutex.Lock()
+	s.clearShutdownLocked()
+	s.shutdownMutex.Unlock()
+	s.hoursMutex.Lock()
+	s.clearOpenHoursLocked()
+	s.hoursMutex.Unlock()
+
+	s.closeSessions()
+	s.closeConns()
+	s.stopBots()
+	s.webhooks.Close()
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.lifecycleMutex.Lock()
+		remaining := len(s.sessions) + len(s.conns)
+		s.lifecycleMutex.Unlock()
+		if remaining == 0 {
+			s.logger.Info("聊天室已停止", "event", "stop")
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// 聊天室停止（调用 Stop 或 /close 到期）后关闭
+func (s *ChatServer) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *ChatServer) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// 把消息放入广播队列；聊天室停止后丢弃
+func (s *ChatServer) publish(msg Message) {
+	select {
+	case s.broadcast <- msg:
+	case <-s.done:
+	}
+}
+
+// 记录监听器以便 Stop 时关闭；聊天室已停止时直接关闭监听器
+func (s *ChatServer) addListener(l net.Listener) error {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+	if s.stopped() {
+		l.Close()
+		return ErrServerStopped
+	}
+	s.listeners = append(s.listeners, l)
+	return nil
+}
+
+// 监听器的 Accept 失败：聊天室停止导致的关闭视为正常结束
+func (s *ChatServer) acceptError(err error) error {
+	if s.stopped() && errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// 登记一个会话（含登录阶段），聊天室已停止时返回 false
+func (s *ChatServer) beginSession(c *Client) bool {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+	if s.stopped() {
+		return false
+	}
+	s.sessions[c] = struct{}{}
+	return true
+}
+
+// 断开所有进行中的会话（含登录阶段），会话各自结束并广播离开消息
+func (s *ChatServer) closeSessions() {
+	s.lifecycleMutex.Lock()
+	sessions := make([]*Client, 0, len(s.sessions))
+	for c := range s.sessions {
+		sessions = append(sessions, c)
+	}
+	s.lifecycleMutex.Unlock()
+	for _, c := range sessions {
+		if c.transport != nil {
+			c.transport.Close()
+		}
+	}
+}
+
+// 登记一个刚接受的原始连接，会话开始之前（注册、握手阶段）Stop 也能关闭它；
+// 聊天室已停止时直接关闭连接并返回 false
+func (s *ChatServer) trackConn(c net.Conn) bool {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+	if s.stopped() {
+		c.Close()
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *ChatServer) untrackConn(c net.Conn) {
+	s.lifecycleMutex.Lock()
+	delete(s.conns, c)
+	s.lifecycleMutex.Unlock()
+}
+
+// 关闭所有登记的原始连接
+func (s *ChatServer) closeConns() {
+	s.lifecycleMutex.Lock()
+	conns := make([]net.Conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.lifecycleMutex.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+func (s *ChatServer) endSession(c *Client) {
+	s.lifecycleMutex.Lock()
+	delete(s.sessions, c)
+	s.lifecycleMutex.Unlock()
+}
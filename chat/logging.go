@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 日志配置
+type LogConfig struct {
+	Format  string // 输出格式：text 或 json
+	Level   string // 日志级别：debug/info/warn/error
+	Privacy bool   // 隐私模式：不记录真实IP和消息内容
+}
+
+// 按配置创建结构化日志记录器
+func NewLogger(w io.Writer, cfg LogConfig) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("未知的日志级别：%s", cfg.Level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("未知的日志格式：%s", cfg.Format)
+	}
+}
+
+// 客户端相关事件的通用日志字段；隐私模式下省略真实IP
+func (s *ChatServer) logAttrs(event, userID, ip string) []any {
+	attrs := []any{"event", event, "room", defaultRoom, "masked_ip", maskIP(ip)}
+	if userID != "" {
+		attrs = append(attrs, "user", userID)
+	}
+	if !s.logPrivacy {
+		attrs = append(attrs, "ip", ip)
+	}
+	return attrs
+}
+
+// 日志中的错误字段。网络错误（如 *net.OpError）的文本含有对端的 IP:端口，
+// 隐私模式下只记录最内层的错误（如 connection reset by peer）
+func (s *ChatServer) errorAttr(err error) slog.Attr {
+	if s.logPrivacy {
+		for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(inner) {
+			err = inner
+		}
+	}
+	return slog.Any("error", err)
+}
@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"demogo/transport"
+)
+
+// 写入总是失败的传输
+type failingTransport struct{ err error }
+
+func (t failingTransport) Name() string                            { return "test" }
+func (t failingTransport) Write([]byte) error                      { return t.err }
+func (t failingTransport) WritePrepared(*transport.Prepared) error { return t.err }
+func (t failingTransport) Read() ([]byte, error)                   { return nil, t.err }
+func (t failingTransport) Close() error                            { return nil }
+
+func TestPrivacyLogOmitsPeerAddress(t *testing.T) {
+	const peerIP = "203.0.113.7"
+	// 与对端断开后 TCP 写失败时的错误
+	writeErr := &net.OpError{
+		Op:     "write",
+		Net:    "tcp",
+		Source: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 18080},
+		Addr:   &net.TCPAddr{IP: net.ParseIP(peerIP), Port: 51234},
+		Err:    os.NewSyscallError("write", syscall.EPIPE),
+	}
+
+	for _, privacy := range []bool{true, false} {
+		var buf bytes.Buffer
+		s := NewChatServer(testPassword)
+		s.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)), privacy)
+		c := s.newClient(peerIP)
+		c.UserID, c.codec, c.transport = "alice", defaultCodec, failingTransport{err: writeErr}
+
+		s.fanOut(Message{Type: "chat", Content: "hi"}, []*Client{c})
+
+		out := buf.String()
+		if !strings.Contains(out, "send_error") || !strings.Contains(out, "broken pipe") {
+			t.Fatalf("privacy=%v：没有记录发送失败：%s", privacy, out)
+		}
+		if leaked := strings.Contains(out, peerIP); leaked == privacy {
+			t.Errorf("privacy=%v：日志中出现真实IP=%v：%s", privacy, leaked, out)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 聊天室监控指标，每个 ChatServer 使用独立的注册表
+type chatMetrics struct {
+	registry             *prometheus.Registry
+	messagesBroadcast    *prometheus.CounterVec   // 按消息类型统计的广播条数
+	sendFailures         prometheus.Counter       // 向客户端发送消息失败次数（广播和直接回复）
+	loginFailures        prometheus.Counter       // 密码错误次数
+	rejectedUpgrades     *prometheus.CounterVec   // 被拒绝的WebSocket握手（按原因）
+	regionLookupDuration *prometheus.HistogramVec // 归属地查询耗时（按结果分类）
+}
+
+// 新建监控指标并注册到独立的注册表
+func newChatMetrics(s *ChatServer) *chatMetrics {
+	m := &chatMetrics{
+		registry: prometheus.NewRegistry(),
+		messagesBroadcast: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chatroom_messages_broadcast_total",
+			Help: "广播的消息数量（按消息类型）",
+		}, []string{"type"}),
+		sendFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chatroom_send_failures_total",
+			Help: "向客户端发送消息失败的次数（含广播和直接回复）",
+		}),
+		loginFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chatroom_login_failures_total",
+			Help: "登录密码错误的次数",
+		}),
+		rejectedUpgrades: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chatroom_rejected_upgrades_total",
+			Help: "被拒绝的WebSocket握手次数（按原因）",
+		}, []string{"reason"}),
+		regionLookupDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chatroom_region_lookup_duration_seconds",
+			Help:    "IP归属地查询耗时（按查询结果分类）",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		m.messagesBroadcast,
+		m.sendFailures,
+		m.loginFailures,
+		m.rejectedUpgrades,
+		m.regionLookupDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "chatroom_connected_clients",
+			Help: "当前已登录的客户端数量",
+		}, func() float64 {
+			s.clientsMutex.RLock()
+			defer s.clientsMutex.RUnlock()
+			return float64(len(s.clients))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "chatroom_broadcast_queue_depth",
+			Help: "广播通道中等待发送的消息数量",
+		}, func() float64 {
+			return float64(len(s.broadcast))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "chatroom_room_members",
+			Help:        "房间内的成员数量（含机器人）",
+			ConstLabels: prometheus.Labels{"room": defaultRoom},
+		}, func() float64 {
+			s.clientsMutex.RLock()
+			defer s.clientsMutex.RUnlock()
+			return float64(len(s.clients) + len(s.bots))
+		}),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// 监控指标的HTTP处理器（Prometheus文本格式）
+func (s *ChatServer) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 抓取 /metrics，返回 "指标名{标签}" -> 值
+func (ts *testServer) scrapeMetrics(t *testing.T) map[string]float64 {
+	t.Helper()
+	resp, err := http.Get(ts.httpURL + "/metrics")
+	if err != nil {
+		t.Fatalf("抓取监控指标失败：%v", err)
+	}
+	defer resp.Body.Close()
+	metrics := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("无法解析指标行 %q：%v", line, err)
+		}
+		metrics[line[:i]] = v
+	}
+	return metrics
+}
+
+func TestMetricsAfterTraffic(t *testing.T) {
+	ts := newTestServer(t)
+	before := ts.scrapeMetrics(t)
+	if v := before["chatroom_connected_clients"]; v != 0 {
+		t.Fatalf("初始在线人数 = %v", v)
+	}
+
+	alice := ts.login(t, "alice")
+	ts.login(t, "bob")
+	alice.expectJoin("bob")
+	alice.chat("你好")
+	alice.expect("chat", "你好")
+
+	// 写入失败的客户端：直接回复和广播各失败一次
+	broken := ts.newClient("203.0.113.7")
+	broken.UserID, broken.transport = "broken", failingTransport{err: errors.New("broken pipe")}
+	if err := broken.Send(Message{Type: "system", Content: "直接回复"}); err == nil {
+		t.Fatal("向失败的传输发送应返回错误")
+	}
+	ts.clientsMutex.Lock()
+	ts.clients[broken.ID] = broken
+	ts.clientsMutex.Unlock()
+	alice.chat("广播")
+	// 广播逐条处理：收到下一条消息时上一条已发给所有客户端
+	alice.chat("marker")
+	alice.expect("chat", "marker")
+
+	after := ts.scrapeMetrics(t)
+	for name, want := range map[string]float64{
+		"chatroom_connected_clients":                        2, // 广播失败的客户端已被移除
+		`chatroom_messages_broadcast_total{type="join"}`:    2,
+		`chatroom_messages_broadcast_total{type="chat"}`:    3,
+		`chatroom_room_members{room="` + defaultRoom + `"}`: 2,
+		"chatroom_send_failures_total":                      before["chatroom_send_failures_total"] + 2,
+	} {
+		if got, ok := after[name]; !ok || got != want {
+			t.Errorf("%s = %v（存在=%v），应为 %v", name, got, ok, want)
+		}
+	}
+}
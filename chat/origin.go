@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 解析 -allowed-origins 参数：逗号分隔，支持 https://chat.example.com、example.com:8443、*.example.com
+func ParseAllowedOrigins(s string) []string {
+	var origins []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part != "" {
+			origins = append(origins, strings.TrimSuffix(part, "/"))
+		}
+	}
+	return origins
+}
+
+// 检查WebSocket握手的Origin：默认只允许同源，另外允许白名单中的来源
+// 没有Origin头的请求（非浏览器客户端）直接放行，浏览器总会带上Origin
+func (s *ChatServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, pattern := range s.allowedOrigins {
+		if matchOrigin(pattern, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断来源是否匹配一条白名单规则
+func matchOrigin(pattern string, origin *url.URL) bool {
+	// 规则带协议时协议也必须一致
+	if scheme, rest, ok := strings.Cut(pattern, "://"); ok {
+		if !strings.EqualFold(scheme, origin.Scheme) {
+			return false
+		}
+		pattern = rest
+	}
+	// 规则带端口时比较 host:port，否则只比较主机名
+	host := strings.ToLower(origin.Hostname())
+	if _, port, err := net.SplitHostPort(pattern); err == nil && port != "" {
+		host = strings.ToLower(origin.Host)
+	}
+	// *.example.com 匹配任意子域名（不含 example.com 本身）
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// 拒绝跨域的WebSocket握手：记录日志、计数，并返回明确的 403 说明
+func (s *ChatServer) rejectOrigin(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	s.metrics.rejectedUpgrades.WithLabelValues("origin").Inc()
+	s.logger.Warn("拒绝跨域WebSocket连接", append(s.logAttrs("origin_reject", "", clientIPFromRequest(r)), "origin", origin)...)
+	http.Error(w, "403 Forbidden: 来源 "+origin+" 不在允许列表中，请从聊天室页面访问", http.StatusForbidden)
+}
@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, origin string
+		want            bool
+	}{
+		{"chat.example.com", "https://chat.example.com", true},
+		{"chat.example.com", "http://chat.example.com:8080", true}, // 规则不带端口时只比较主机名
+		{"chat.example.com", "https://CHAT.Example.com", true},
+		{"chat.example.com", "https://evil-chat.example.com", false},
+		{"chat.example.com", "https://chat.example.com.evil.com", false},
+		{"*.example.com", "https://a.example.com", true},
+		{"*.example.com", "https://a.b.example.com", true},
+		{"*.example.com", "https://example.com", false}, // 不含根域名本身
+		{"*.example.com", "https://badexample.com", false},
+		{"example.com:8443", "https://example.com:8443", true},
+		{"example.com:8443", "https://example.com", false},
+		{"example.com:8443", "https://example.com:9443", false},
+		{"https://chat.example.com", "https://chat.example.com", true},
+		{"https://chat.example.com", "http://chat.example.com", false},
+		{"https://*.example.com", "https://a.example.com", true},
+		{"https://*.example.com", "http://a.example.com", false},
+		{"http://localhost:3000", "http://localhost:3000", true},
+		{"http://localhost:3000", "http://localhost:3001", false},
+	} {
+		origin, err := url.Parse(tc.origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns := ParseAllowedOrigins(tc.pattern)
+		if len(patterns) != 1 {
+			t.Fatalf("规则 %q 解析结果 = %v", tc.pattern, patterns)
+		}
+		if got := matchOrigin(patterns[0], origin); got != tc.want {
+			t.Errorf("matchOrigin(%q, %q) = %v，应为 %v", tc.pattern, tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	s := NewChatServer(testPassword)
+	s.SetAllowedOrigins(ParseAllowedOrigins(" https://app.example.com/ , *.partner.com"))
+	for _, tc := range []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},                         // 非浏览器客户端不带 Origin
+		{"http://chat.local:18080", true},  // 同源
+		{"https://app.example.com", true},  // 白名单（末尾的 / 已去掉）
+		{"https://x.partner.com", true},    // 通配子域名
+		{"null", false},                    // 沙箱 iframe、file:// 页面
+		{"https://evil.com", false},        // 不在白名单
+		{"http://app.example.com", false},  // 协议不一致
+		{"://bad", false},                  // 无法解析
+		{"http://chat.local:18081", false}, // 同主机不同端口
+		{"https://partner.com", false},     // 通配不含根域名
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://chat.local:18080/ws", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if got := s.checkOrigin(r); got != tc.want {
+			t.Errorf("Origin %q：checkOrigin = %v，应为 %v", tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestRejectedOriginUpgrade(t *testing.T) {
+	ts := newTestServer(t)
+	ts.SetAllowedOrigins(ParseAllowedOrigins("https://app.example.com"))
+	metric := `chatroom_rejected_upgrades_total{reason="origin"}`
+	before := ts.scrapeMetrics(t)[metric]
+
+	_, resp, err := websocket.DefaultDialer.Dial(ts.url, http.Header{"Origin": {"https://evil.com"}})
+	if err == nil {
+		t.Fatal("不在白名单中的来源应被拒绝")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("跨域握手应返回 403，实际：%v", resp)
+	}
+	if got := ts.scrapeMetrics(t)[metric]; got != before+1 {
+		t.Errorf("%s = %v，应为 %v", metric, got, before+1)
+	}
+
+	// 白名单中的来源可以正常连接
+	conn, _, err := websocket.DefaultDialer.Dial(ts.url, http.Header{"Origin": {"https://app.example.com"}})
+	if err != nil {
+		t.Fatalf("白名单中的来源连接失败：%v", err)
+	}
+	conn.Close()
+}
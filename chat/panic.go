@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 支持老板键的客户端在 hello 的 features 中声明该功能
+const featurePanic = "panic"
+
+// 两次 /panic 之间的最短间隔，防止刷屏
+const panicCooldown = 30 * time.Second
+
+// 老板键模式下不再投递的消息类型（聊天内容和进出通知），系统通知照常投递
+var panicSuppressedTypes = []string{"chat", "private", "join", "leave"}
+
+// 客户端是否处于老板键模式：是则记一条未显示的消息并返回 true
+func (c *Client) suppressDuringPanic(msgType string) bool {
+	if !c.panicking.Load() || !slices.Contains(panicSuppressedTypes, msgType) {
+		return false
+	}
+	c.panicMissed.Add(1)
+	return true
+}
+
+// 处理 /panic：冷却时间内拒绝，否则广播 panic 事件
+func (s *ChatServer) handlePanic(client *Client, msg Message) {
+	s.clientsMutex.Lock()
+	wait := s.lastPanic.Add(panicCooldown).Sub(s.clock.Now())
+	if wait <= 0 {
+		s.lastPanic = s.clock.Now()
+	}
+	s.clientsMutex.Unlock()
+	if wait > 0 {
+		client.Send(Message{
+			Type:    "system",
+			Content: fmt.Sprintf("【系统通知】老板键刚刚按过，请 %s 后再试", formatCountdown((wait + time.Second - 1).Truncate(time.Second))),
+			Time:    msg.Time,
+		})
+		return
+	}
+	s.logger.Info("按下老板键", s.logAttrs("panic", client.UserID, client.IP)...)
+	s.publish(Message{
+		Type:    "panic",
+		Content: fmt.Sprintf("%s 按下了老板键", client.UserID),
+		UserID:  client.UserID,
+		Time:    msg.Time,
+	})
+}
+
+// 处理 /resume：退出老板键模式，告知期间未显示的消息数
+func (s *ChatServer) handleResume(client *Client, msg Message) {
+	if !client.panicking.Swap(false) {
+		client.Send(Message{
+			Type:    "system",
+			Content: "【系统通知】当前不在老板键模式",
+			Time:    msg.Time,
+		})
+		return
+	}
+	content := "已恢复聊天"
+	if missed := client.panicMissed.Swap(0); missed > 0 {
+		content = fmt.Sprintf("已恢复聊天，期间有 %d 条消息未显示", missed)
+	}
+	client.Send(Message{
+		Type:    "resume",
+		Content: content,
+		Time:    msg.Time,
+	})
+}
+
+// 广播 panic 事件：支持老板键的客户端进入老板键模式并收到 panic 事件，
+// 其他客户端（终端、IRC 等）只收到一条系统通知。返回应收到 panic 事件的客户端
+func (s *ChatServer) enterPanic(msg Message, clients []*Client) []*Client {
+	var capable, others []*Client
+	for _, c := range clients {
+		if c.panicCapable {
+			c.panicking.Store(true)
+			capable = append(capable, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+	s.fanOut(Message{
+		Type:    "system",
+		Content: "【系统通知】" + msg.Content,
+		Time:    msg.Time,
+	}, others)
+	return capable
+}
@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// 私聊：/msg 用户ID 内容，只发给对方和自己，不经过广播（机器人和Webhook都看不到）
+
+// 按用户ID查找在线客户端（用户ID已做 HTML 转义，也接受转义前的写法）
+func (s *ChatServer) findClientByUserID(userID string) *Client {
+	escaped := escapeHTML(userID)
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	for _, c := range s.clients {
+		if c.UserID == userID || c.UserID == escaped {
+			return c
+		}
+	}
+	return nil
+}
+
+// 拆分 /msg 的参数：用户ID可能包含空格，优先匹配最长的在线用户ID，找不到时取第一个词
+func (s *ChatServer) splitPrivateTarget(args string) (*Client, string, string) {
+	args = strings.TrimSpace(args)
+	escaped := escapeHTML(args)
+	var to *Client
+	s.clientsMutex.RLock()
+	for _, c := range s.clients {
+		if (strings.HasPrefix(args, html.UnescapeString(c.UserID)+" ") || strings.HasPrefix(escaped, c.UserID+" ")) &&
+			(to == nil || len(c.UserID) > len(to.UserID)) {
+			to = c
+		}
+	}
+	s.clientsMutex.RUnlock()
+	if to != nil {
+		_, text, _ := strings.Cut(escaped[len(to.UserID):], " ")
+		return to, to.UserID, html.UnescapeString(text)
+	}
+	target, text, _ := strings.Cut(args, " ")
+	return nil, target, text
+}
+
+// 处理 /msg 命令，msg 为已补全发送者信息的命令消息
+func (s *ChatServer) handlePrivateMessage(from *Client, msg Message, args string) {
+	reply := func(content string) {
+		from.Send(Message{Type: "system", Content: "【系统通知】" + content, Time: msg.Time})
+	}
+
+	to, target, text := s.splitPrivateTarget(args)
+	if target == "" {
+		reply("用法：/msg 用户ID 内容")
+		return
+	}
+	content, err := sanitizeChatContent(text)
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+	if s.isMuted(from) {
+		reply("你已被管理员禁言，暂时无法发言")
+		return
+	}
+	if to == nil {
+		reply(fmt.Sprintf("用户 %s 不在线", escapeHTML(target)))
+		return
+	}
+
+	msg.Type = "private"
+	msg.Content = content
+	// 对方处于老板键模式时不投递（/resume 时告知对方未显示的消息数），告诉发送者消息没有送达
+	if to.suppressDuringPanic(msg.Type) {
+		reply(fmt.Sprintf("%s 正处于老板键模式，私聊未送达，请稍后再发", to.UserID))
+		return
+	}
+	if err := to.Send(msg); err != nil {
+		reply("私聊发送失败")
+		return
+	}
+	// 发送者收到一份带接收者的副本（IRC 客户端自己会显示，不回显）
+	if to != from && !from.noEcho {
+		msg.Content = fmt.Sprintf("→ %s：%s", to.UserID, content)
+		from.Send(msg)
+	}
+	s.logger.Debug("私聊消息", append(s.logAttrs("private", from.UserID, from.IP), "to", to.UserID)...)
+}
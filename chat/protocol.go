@@ -0,0 +1,248 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"demogo/protocol"
+)
+
+// 协议版本
+const (
+	ProtocolV1 = protocol.V1
+	ProtocolV2 = protocol.V2
+
+	CurrentProtocolVersion = protocol.CurrentVersion
+)
+
+// 服务端支持的协议版本
+var supportedProtocolVersions = []int{ProtocolV1, ProtocolV2}
+
+// 消息载荷定义在 protocol 包中，与 cmd/chatcli 共用
+type (
+	HelloPayload    = protocol.HelloPayload
+	PasswordPayload = protocol.PasswordPayload
+	SetIDPayload    = protocol.SetIDPayload
+	ChatPayload     = protocol.ChatPayload
+)
+
+// 客户端消息类型及其载荷结构
+var clientMessageKinds = map[string]reflect.Type{
+	"hello":    reflect.TypeOf(HelloPayload{}),
+	"password": reflect.TypeOf(PasswordPayload{}),
+	"setid":    reflect.TypeOf(SetIDPayload{}),
+	"chat":     reflect.TypeOf(ChatPayload{}),
+}
+
+// 服务端可能发出的消息类型
+var serverMessageTypes = []string{
+	"hello", "error", "password", "setid", "welcome", "join", "leave",
+	"chat", "private", "online", "help", "color", "system", "closed", "panic", "resume",
+}
+
+// 协议错误：会以 error 事件返回给客户端，连接不会断开
+type protocolError struct {
+	msg string
+}
+
+func (e *protocolError) Error() string { return e.msg }
+
+func newProtocolError(format string, args ...interface{}) error {
+	return &protocolError{msg: fmt.Sprintf(format, args...)}
+}
+
+// 严格解析一条 v2 客户端消息：类型必须已知且符合当前阶段，不允许未知字段
+func decodeClientMessage(codec Codec, data []byte, expect string) (Message, error) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := codec.Unmarshal(data, &envelope, false); err != nil {
+		return Message{}, newProtocolError("消息无法按 %s 格式解析", codec.Name())
+	}
+	kind, ok := clientMessageKinds[envelope.Type]
+	if !ok {
+		return Message{}, newProtocolError("未知的消息类型：%q", envelope.Type)
+	}
+	if envelope.Type != expect {
+		return Message{}, newProtocolError("当前需要 %s 消息，收到 %s", expect, envelope.Type)
+	}
+
+	payload := reflect.New(kind).Interface()
+	if err := codec.Unmarshal(data, payload, true); err != nil {
+		return Message{}, newProtocolError("%s 消息格式错误：%v", envelope.Type, err)
+	}
+
+	msg := Message{Type: envelope.Type}
+	switch p := payload.(type) {
+	case *PasswordPayload:
+		msg.Content = p.Content
+	case *SetIDPayload:
+		msg.Content = p.Content
+	case *ChatPayload:
+		msg.Content = p.Content
+	}
+	return msg, nil
+}
+
+// 从客户端支持的版本中选出双方都支持的最高版本
+func negotiateProtocol(versions []int) (int, bool) {
+	best := 0
+	for _, v := range versions {
+		for _, supported := range supportedProtocolVersions {
+			if v == supported && v > best {
+				best = v
+			}
+		}
+	}
+	return best, best > 0
+}
+
+// 读取客户端在当前阶段（expect）的一条消息
+// 第一条消息若为 hello 则完成版本协商，否则按旧版协议处理；
+// v2 下格式错误的消息会回复 error 事件并继续等待，只有连接错误才返回 error
+func (s *ChatServer) receive(c *Client, expect string) (Message, error) {
+	for {
+		data, err := c.receiveRaw()
+		if err != nil {
+			return Message{}, err
+		}
+
+		// 尚未确定协议版本：处理握手
+		if c.Protocol == 0 {
+			var hello HelloPayload
+			if c.codec.Unmarshal(data, &hello, false) == nil && hello.Type == "hello" {
+				version, ok := negotiateProtocol(hello.Versions)
+				if !ok {
+					c.Send(Message{
+						Type:    "error",
+						Content: fmt.Sprintf("不支持的协议版本，服务端支持：%v", supportedProtocolVersions),
+						Time:    s.timestamp(),
+					})
+					return Message{}, errors.New("协议版本协商失败")
+				}
+				c.Protocol = version
+				c.panicCapable = slices.Contains(hello.Features, featurePanic)
+				c.Send(Message{
+					Type:    "hello",
+					Content: fmt.Sprintf("终端聊天室 %s，协议 v%d", Version, version),
+					Version: version,
+					Time:    s.timestamp(),
+				})
+				continue
+			}
+			c.Protocol = ProtocolV1
+		}
+
+		// 旧版协议：不校验类型，只取 content
+		if c.Protocol == ProtocolV1 {
+			var msg Message
+			if err := c.codec.Unmarshal(data, &msg, false); err != nil {
+				return Message{}, err
+			}
+			return msg, nil
+		}
+
+		msg, err := decodeClientMessage(c.codec, data, expect)
+		if err != nil {
+			c.Send(Message{
+				Type:    "error",
+				Content: err.Error(),
+				Time:    s.timestamp(),
+			})
+			continue
+		}
+		return msg, nil
+	}
+}
+
+// 导出协议的 JSON Schema（由Go类型通过反射生成）
+func ProtocolSchema() map[string]interface{} {
+	defs := map[string]interface{}{}
+	var clientRefs []interface{}
+	for _, name := range []string{"hello", "password", "setid", "chat"} {
+		schema := schemaForType(clientMessageKinds[name])
+		schema["properties"].(map[string]interface{})["type"] = map[string]interface{}{"const": name}
+		defs[name] = schema
+		clientRefs = append(clientRefs, map[string]interface{}{"$ref": "#/$defs/" + name})
+	}
+
+	server := schemaForType(reflect.TypeOf(Message{}))
+	server["properties"].(map[string]interface{})["type"] = map[string]interface{}{"enum": serverMessageTypes}
+	defs["serverMessage"] = server
+
+	return map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         fmt.Sprintf("chatroom-protocol-v%d", CurrentProtocolVersion),
+		"title":       fmt.Sprintf("终端聊天室 WebSocket 协议 v%d", CurrentProtocolVersion),
+		"description": "根结构为客户端发给服务端的消息；服务端发出的消息见 $defs/serverMessage",
+		"oneOf":       clientRefs,
+		"$defs":       defs,
+	}
+}
+
+// 把Go类型转换为 JSON Schema 片段
+func schemaForType(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			prop := schemaForType(f.Type)
+			if desc := f.Tag.Get("desc"); desc != "" {
+				prop["description"] = desc
+			}
+			properties[name] = prop
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+// 协议 JSON Schema 的HTTP接口
+func HandleProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(ProtocolSchema())
+}
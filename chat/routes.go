@@ -0,0 +1,93 @@
+package chat
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+
+	"demogo/clock"
+)
+
+// 使用指定的日志记录器；privacy 为 true 时日志中不记录真实IP和消息内容
+func (s *ChatServer) SetLogger(logger *slog.Logger, privacy bool) {
+	s.logger = logger
+	s.logPrivacy = privacy
+}
+
+// 替换时钟（测试中使用 clock.Fake），需在接受连接前调用
+func (s *ChatServer) SetClock(c clock.Clock) {
+	s.clock = c
+	s.startedAt = c.Now()
+}
+
+// 设置同源之外允许连接WebSocket的来源（见 ParseAllowedOrigins）
+func (s *ChatServer) SetAllowedOrigins(origins []string) {
+	s.allowedOrigins = origins
+}
+
+// 设置可信的反向代理（见 ParseTrustedProxies），需在处理请求前调用；
+// 未设置时封禁一律使用连接的对端IP
+func (s *ChatServer) SetTrustedProxies(proxies []netip.Prefix) {
+	s.trustedProxies = proxies
+}
+
+// 设置出站Webhook分发器（为空则不推送）并启动投递，需在 SetLogger、SetClock 之后调用；
+// 聊天室停止时分发器随之关闭
+func (s *ChatServer) SetWebhooks(d *WebhookDispatcher) {
+	s.webhooks = d
+	if d != nil {
+		d.start(s.logger, s.clock)
+	}
+}
+
+// 设置入站消息接口的Bearer Token（为空则关闭接口），需在处理请求前调用
+func (s *ChatServer) SetAPIToken(token string) {
+	s.apiToken = token
+}
+
+// 设置管理接口的Bearer Token（为空则关闭接口），需在处理请求前调用
+func (s *ChatServer) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// 使用目录下的前端资源代替打包的页面（调试主题时使用），需在处理请求前调用
+func (s *ChatServer) SetWebDir(dir string) error {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s 不是目录", dir)
+		}
+	}
+	s.webDir = dir
+	return nil
+}
+
+// 聊天室的全部HTTP路由（页面、WebSocket、SSE、接口和监控），路由在第一次请求时生成。
+// 可以挂载到已有服务的子路径下，页面中的地址都是相对路径：
+//
+//	mux.Handle("/chat/", http.StripPrefix("/chat", server))
+func (s *ChatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.routesOnce.Do(func() { s.routes = s.newRouter() })
+	s.routes.ServeHTTP(w, r)
+}
+
+func (s *ChatServer) newRouter() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", newStaticHandler(s.webDir))
+	mux.HandleFunc("/ws", s.HandleClient)
+	mux.HandleFunc("GET /sse", s.HandleSSE)
+	mux.HandleFunc("POST /sse/{session}", s.HandleSSEPost)
+	mux.HandleFunc("POST /api/rooms/{room}/messages", requireBearer(s.apiToken, s.HandlePostMessage))
+	s.RegisterAdminRoutes(mux, s.adminToken)
+	mux.Handle("/metrics", s.MetricsHandler())
+	mux.HandleFunc("GET /protocol/schema.json", HandleProtocolSchema)
+	mux.HandleFunc("GET /healthz", s.HandleHealthz)
+	mux.HandleFunc("GET /readyz", s.HandleReadyz)
+	mux.HandleFunc("GET /status", s.HandleStatus)
+	return mux
+}
@@ -0,0 +1,808 @@
+// Package chat 是终端聊天室的核心：登录流程、命令、广播，以及网页/SSE/SSH/TCP/IRC 等接入方式
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"demogo/clock"
+	"demogo/geo"
+	"demogo/protocol"
+	"demogo/termui"
+	"demogo/transport"
+	"demogo/transport/ws"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 聊天室版本号
+const Version = "v2.1"
+
+// 客户端结构体（含IP/归属地/用户ID）
+type Client struct {
+	transport    Transport    // 传输层（WebSocket/SSE/SSH/TCP/IRC）
+	ID           uint64       // 会话编号（服务端分配，全局唯一）
+	UserID       string       // 用户ID（自定义/随机）
+	IP           string       // 客户端IP
+	remoteIP     string       // 连接的对端IP（不含端口），不受 X-Forwarded-For 等请求头影响
+	Region       string       // IP归属地（省-市-运营商）
+	Color        string       // 用户随机颜色
+	ConnectedAt  time.Time    // 连接建立时间
+	Protocol     int          // 协商后的协议版本，0 表示尚未确定
+	codec        Codec        // 消息编码格式（JSON/MessagePack/CBOR）
+	BytesIn      atomic.Int64 // 收到的字节数
+	BytesOut     atomic.Int64 // 发出的字节数
+	WireBytesIn  atomic.Int64 // 线路上实际收到的字节数（含帧头，压缩后）
+	WireBytesOut atomic.Int64 // 线路上实际发出的字节数（含帧头，压缩后）
+	mutedUntil   time.Time    // 禁言截止时间（受 clientsMutex 保护）
+	kicked       atomic.Bool  // 是否被管理员踢出
+	noEcho       bool         // 自己发出的群聊消息和加入通知不回显（IRC 客户端自己会显示）
+	panicCapable bool         // 客户端是否支持老板键（握手时声明）
+	panicking    atomic.Bool  // 是否处于老板键模式（发送 /resume 前不投递聊天消息）
+	panicMissed  atomic.Int64 // 老板键模式下未投递的消息数
+	writeMu      sync.Mutex   // 同一连接不允许并发写
+
+	sendFailures prometheus.Counter // 发送失败计数（广播和直接回复都统计，为空则不统计）
+}
+
+// 是否为不需要回显给该客户端的自己发出的消息（按会话而不是用户ID判断，用户ID可以重复）
+func (c *Client) isEcho(msg Message) bool {
+	return c.noEcho && msg.Session == c.ID && (msg.Type == "chat" || msg.Type == "join")
+}
+
+// 发送消息给客户端（按协商的格式编码）
+func (c *Client) Send(msg Message) error {
+	data, err := c.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.sendEncoded(data)
+}
+
+// 发送已编码的消息（串行写入，并统计发出的字节数）
+func (c *Client) sendEncoded(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.transport.Write(data); err != nil {
+		c.countSendFailure()
+		return err
+	}
+	c.BytesOut.Add(int64(len(data)))
+	return nil
+}
+
+// 发送预编码的消息
+func (c *Client) sendPrepared(p *transport.Prepared) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.transport.WritePrepared(p); err != nil {
+		c.countSendFailure()
+		return err
+	}
+	c.BytesOut.Add(int64(len(p.Data)))
+	return nil
+}
+
+func (c *Client) countSendFailure() {
+	if c.sendFailures != nil {
+		c.sendFailures.Inc()
+	}
+}
+
+// 读取客户端发来的一条原始消息，并统计收到的字节数
+func (c *Client) receiveRaw() ([]byte, error) {
+	data, err := c.transport.Read()
+	if err != nil {
+		return nil, err
+	}
+	c.BytesIn.Add(int64(len(data)))
+	return data, nil
+}
+
+// 消息结构体（前端<->后端通信格式），定义在 protocol 包中
+type Message = protocol.Message
+
+// 客户端传输层，定义在 transport 包中
+type Transport = transport.Transport
+
+// 聊天室核心管理（含固定登录密码）
+type ChatServer struct {
+	clients          map[uint64]*Client // 会话编号 -> 已登录的客户端
+	broadcast        chan Message
+	clientsMutex     sync.RWMutex
+	fixedPassword    string
+	shutdownMutex    sync.Mutex // 保护关闭定时器相关字段
+	shutdownTimers   []clock.Timer
+	shutdownGen      uint64          // 关闭计划的版本，每次设置或取消时递增，旧计划的定时器据此失效
+	shutdownAt       time.Time       // 计划关闭的时间点（零值表示未设置）
+	shutdownWarnings []time.Duration // 关闭前的提醒时间点，从远到近
+	hoursMutex       sync.Mutex      // 保护开放时段相关字段
+	openHours        *OpenHours      // 开放时段（为空表示不限制）
+	hoursTimers      []clock.Timer
+	hoursGen         uint64 // 开放时段安排的版本，重新安排时递增
+	nextClientID     atomic.Uint64
+	bans             map[string]time.Time // 封禁的IP -> 截止时间（零值表示永久），受 clientsMutex 保护
+	trustedProxies   []netip.Prefix       // 可信的反向代理，来自这些地址的连接按转发头中的IP封禁
+	lastPanic        time.Time            // 上次按下老板键的时间，受 clientsMutex 保护
+	bots             []*botClient         // 内置机器人（伪客户端）
+	webhooks         *WebhookDispatcher   // 出站Webhook（可为空）
+	metrics          *chatMetrics         // 监控指标
+	logger           *slog.Logger         // 结构化日志
+	logPrivacy       bool                 // 隐私模式：日志中不记录真实IP和消息内容
+	startedAt        time.Time            // 服务启动时间
+	resolver         resolverHealth       // 归属地查询接口的健康状况
+	upgrader         websocket.Upgrader   // 升级HTTP连接为WebSocket连接
+	allowedOrigins   []string             // 同源之外允许的WebSocket来源
+	compression      CompressionConfig    // permessage-deflate 压缩配置
+	sseMutex         sync.Mutex
+	sseSessions      map[string]*sseTransport // SSE会话编号 -> 会话
+	apiToken         string                   // 入站消息接口的Bearer Token
+	adminToken       string                   // 管理接口的Bearer Token
+	webDir           string                   // 前端资源目录（为空则使用打包的页面）
+	routesOnce       sync.Once
+	routes           http.Handler
+	startOnce        sync.Once
+	stopOnce         sync.Once
+	done             chan struct{}         // Stop 时关闭
+	lifecycleMutex   sync.Mutex            // 保护 listeners、sessions 和 conns
+	listeners        []net.Listener        // SSH/TCP/IRC 监听器，Stop 时关闭
+	sessions         map[*Client]struct{}  // 进行中的会话（含登录阶段）
+	conns            map[net.Conn]struct{} // 已接受、尚未结束的原始连接（含注册、握手阶段），Stop 时关闭
+	clock            clock.Clock           // 计时功能（关闭倒计时、禁言、提醒等）使用的时钟
+}
+
+// 随机ID生成词库
+var adjectives = []string{"快乐", "聪明", "安静", "活泼", "神秘", "勇敢", "幽默", "优雅", "可爱", "帅气"}
+var nouns = []string{"小猫", "小狗", "熊猫", "老虎", "兔子", "狐狸", "海豚", "老鹰", "狮子", "蝴蝶"}
+
+// 预定义颜色列表，确保颜色美观且易于区分
+var colors = []string{
+	"#00ff00", // 绿色
+	"#00ffff", // 青色
+	"#ff0000", // 红色
+	"#ff00ff", // 品红
+	"#ffff00", // 黄色
+	"#0000ff", // 蓝色
+	"#ff6600", // 橙色
+	"#9933ff", // 紫色
+	"#33ff99", // 浅绿色
+	"#ff3399", // 粉红色
+	"#3399ff", // 浅蓝色
+	"#ffcc00", // 金黄色
+	"#00ccff", // 亮蓝色
+	"#ff9900", // 深橙色
+	"#66ff33", // 鲜绿色
+	"#cc00ff", // 深紫色
+	"#ff3333", // 鲜红色
+	"#33ffcc", // 蓝绿色
+	"#ffcc33", // 浅黄色
+	"#9966ff", // 薰衣草色
+	"#33ccff", // 天蓝色
+	"#ff66b3", // 淡紫色
+	"#66ff99", // 薄荷绿
+	"#ff99cc", // 浅粉色
+	"#66ccff", // 冰蓝色
+	"#ffcc66", // 沙褐色
+	"#99ffcc", // 水绿色
+	"#cc66ff", // 淡紫色
+	"#ff3366", // 玫红色
+	"#3366ff", // 深蓝色
+}
+
+// HTML 转义函数，防止 XSS 攻击
+func escapeHTML(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\'':
+			buf.WriteString("&#039;")
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String()
+}
+
+// 单条聊天消息的最大长度（按字符计）
+const maxMessageLength = 1000
+
+var (
+	errEmptyMessage   = errors.New("消息不能为空")
+	errMessageTooLong = fmt.Errorf("消息过长，最多 %d 个字符", maxMessageLength)
+	errControlChars   = errors.New("消息不能包含控制字符")
+)
+
+// 校验聊天内容：去除首尾空白、过滤空消息、限制长度、拒绝终端控制字符，并做 HTML 转义防止 XSS 攻击
+func sanitizeChatContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", errEmptyMessage
+	}
+	if utf8.RuneCountInString(content) > maxMessageLength {
+		return "", errMessageTooLong
+	}
+	if strings.ContainsFunc(content, termui.IsControl) {
+		return "", errControlChars
+	}
+	return escapeHTML(content), nil
+}
+
+// 获取真实客户端 IP 地址，支持反向代理
+func getRealClientIP(r *http.Request) string {
+	// 优先检查反向代理头
+	forwardedFor := r.Header.Get("X-Forwarded-For")
+	if forwardedFor != "" {
+		// X-Forwarded-For 格式: client, proxy1, proxy2
+		parts := strings.Split(forwardedFor, ",")
+		if len(parts) > 0 {
+			// 取第一个非空值作为真实 IP
+			for _, part := range parts {
+				ip := strings.TrimSpace(part)
+				if ip != "" {
+					return ip
+				}
+			}
+		}
+	}
+
+	// 检查 X-Real-IP 头
+	realIP := r.Header.Get("X-Real-IP")
+	if realIP != "" {
+		return realIP
+	}
+
+	//  fallback 到 RemoteAddr
+	return r.RemoteAddr
+}
+
+// 提取客户端纯IP（支持反向代理，兼容IPv6和带端口的IP）
+func clientIPFromRequest(r *http.Request) string {
+	clientIP := getRealClientIP(r)
+
+	// 处理IPv6地址格式 [2001:db8::1]:12345
+	if strings.Contains(clientIP, "[") && strings.Contains(clientIP, "]") {
+		// 提取[]中的内容
+		startIdx := strings.Index(clientIP, "[")
+		endIdx := strings.Index(clientIP, "]")
+		if startIdx < endIdx {
+			clientIP = clientIP[startIdx+1 : endIdx]
+		}
+	} else if strings.Contains(clientIP, ":") {
+		// 处理IPv4地址格式 192.168.1.1:12345
+		ipParts := strings.Split(clientIP, ":")
+		if len(ipParts) > 1 {
+			clientIP = ipParts[0]
+		}
+	}
+
+	// 最终清理，确保没有多余的括号
+	return strings.Trim(clientIP, "[]")
+}
+
+// 处理IP地址的隐私显示
+func maskIP(ip string) string {
+	// 处理IP样式
+	if strings.Count(ip, ".") == 3 {
+		parts := strings.Split(ip, ".")
+		if len(parts) == 4 {
+			return parts[0] + ":" + parts[1] + ":*"
+		}
+	}
+	// 处理IPv6地址
+	if strings.Count(ip, ":") >= 2 {
+		parts := strings.Split(ip, ":")
+		if len(parts) >= 2 {
+			return parts[0] + ":" + parts[1] + ":*"
+		}
+	}
+	return ip
+}
+
+// 新建聊天室（传入固定密码）
+func NewChatServer(fixedPassword string) *ChatServer {
+	s := &ChatServer{
+		clients:          make(map[uint64]*Client),
+		broadcast:        make(chan Message, 200), // 增大广播通道缓冲区
+		fixedPassword:    fixedPassword,
+		bans:             make(map[string]time.Time),
+		sseSessions:      make(map[string]*sseTransport),
+		done:             make(chan struct{}),
+		sessions:         make(map[*Client]struct{}),
+		conns:            make(map[net.Conn]struct{}),
+		clock:            clock.Real,
+		shutdownWarnings: DefaultShutdownWarnings,
+	}
+	s.upgrader = websocket.Upgrader{
+		CheckOrigin:     s.checkOrigin,     // 只允许同源和白名单中的来源
+		Subprotocols:    codecSubprotocols, // 可协商的编码格式，未协商时使用JSON
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024, // 增加缓冲区，防止断连
+	}
+	s.SetCompression(DefaultCompressionConfig)
+	s.metrics = newChatMetrics(s)
+	s.logger = slog.Default()
+	s.startedAt = s.clock.Now()
+	return s
+}
+
+// 初始化随机数种子
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// 消息时间戳（时:分:秒）
+func (s *ChatServer) timestamp() string {
+	return s.clock.Now().Format("15:04:05")
+}
+
+// 生成随机用户ID
+func (s *ChatServer) generateRandomID() string {
+	adj := adjectives[rand.Intn(len(adjectives))]
+	noun := nouns[rand.Intn(len(nouns))]
+	num := rand.Intn(900) + 100
+	return fmt.Sprintf("%s%s%d", adj, noun, num)
+}
+
+// 生成随机用户颜色
+func (s *ChatServer) generateRandomColor() string {
+	color := colors[rand.Intn(len(colors))]
+	return color
+}
+
+// 查询IP归属地，并记录查询耗时和结果到监控指标
+func (s *ChatServer) getIPRegion(ip string) string {
+	start := time.Now()
+	region, outcome := geo.Lookup(ip)
+	s.metrics.regionLookupDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	s.resolver.record(outcome, s.clock.Now())
+	return region
+}
+
+// 广播消息给所有客户端（修复遍历错误，增加错误处理，防止单客户端断连影响全局），聊天室停止后返回
+func (s *ChatServer) Broadcaster() {
+	for {
+		var msg Message
+		select {
+		case msg = <-s.broadcast:
+		case <-s.done:
+			return
+		}
+		s.metrics.messagesBroadcast.WithLabelValues(msg.Type).Inc()
+		s.clientsMutex.RLock()
+		// 遍历前先复制客户端列表，防止遍历中修改
+		clients := make([]*Client, 0, len(s.clients))
+		for _, c := range s.clients {
+			clients = append(clients, c)
+		}
+		s.clientsMutex.RUnlock()
+
+		// 老板键：支持的客户端进入老板键模式，其余客户端收到系统通知
+		if msg.Type == "panic" {
+			clients = s.enterPanic(msg, clients)
+		}
+		s.fanOut(msg, clients)
+
+		// 机器人也能观察到广播消息
+		s.dispatchToBots(msg)
+		// 异步推送到出站Webhook
+		s.webhooks.Publish(msg)
+	}
+}
+
+// 按编码格式预编码一条广播消息
+func prepareMessage(codec Codec, msg Message) (*transport.Prepared, error) {
+	data, err := codec.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return transport.NewPrepared(data, codec.FrameType()), nil
+}
+
+// 把一条消息发给所有客户端：每种编码格式只编码一次，生成 PreparedMessage 后复用帧数据
+func (s *ChatServer) fanOut(msg Message, clients []*Client) {
+	prepared := make(map[Codec]*transport.Prepared)
+	for _, c := range clients {
+		if c.isEcho(msg) || c.suppressDuringPanic(msg.Type) {
+			continue
+		}
+		pm, ok := prepared[c.codec]
+		if !ok {
+			var err error
+			if pm, err = prepareMessage(c.codec, msg); err != nil {
+				s.logger.Error("编码广播消息失败", "event", "encode_error", "codec", c.codec.Name(), "error", err)
+				prepared[c.codec] = nil
+				continue
+			}
+			prepared[c.codec] = pm
+		}
+		if pm == nil {
+			continue
+		}
+		if err := c.sendPrepared(pm); err != nil {
+			s.logger.Warn("发送消息失败，关闭连接", append(s.logAttrs("send_error", c.UserID, c.IP), s.errorAttr(err))...)
+			c.transport.Close()
+			s.clientsMutex.Lock()
+			delete(s.clients, c.ID)
+			s.clientsMutex.Unlock()
+		}
+	}
+}
+
+// 处理单个WebSocket客户端连接（加固错误处理，防止解析失败导致断连）
+func (s *ChatServer) HandleClient(w http.ResponseWriter, r *http.Request) {
+	if s.stopped() {
+		http.Error(w, "503 Service Unavailable: 聊天室已关闭", http.StatusServiceUnavailable)
+		return
+	}
+	// 跨域请求直接拒绝，防止其他网站借用户的浏览器连入聊天室
+	if !s.checkOrigin(r) {
+		s.rejectOrigin(w, r)
+		return
+	}
+
+	// 提取客户端纯IP（支持反向代理，兼容IPv6和带端口的IP）
+	client := s.newHTTPClient(r)
+
+	// 升级为WebSocket连接（包装连接以统计线路上的实际字节数）
+	counting := &ws.CountingResponseWriter{ResponseWriter: w, BytesRead: &client.WireBytesIn, BytesWritten: &client.WireBytesOut}
+	conn, err := s.upgrader.Upgrade(counting, r, nil)
+	if err != nil {
+		s.logger.Warn("升级WebSocket失败", "event", "upgrade_error", s.errorAttr(err))
+		return
+	}
+	client.codec = codecFor(conn.Subprotocol()) // 握手时协商的编码格式
+	wst := ws.New(conn, client.codec.FrameType())
+	if s.compressionRequested(r) {
+		wst.EnableCompression(s.compression.Level, s.compression.Threshold)
+	}
+	client.transport = wst
+	defer func() {
+		// 延迟关闭连接，确保资源释放
+		wst.Close()
+	}()
+
+	s.serveClient(client)
+}
+
+// 为新连接创建客户端
+func (s *ChatServer) newClient(ip string) *Client {
+	return &Client{
+		ID:           s.nextClientID.Add(1),
+		IP:           ip,
+		remoteIP:     ip,
+		ConnectedAt:  s.clock.Now(),
+		codec:        defaultCodec,
+		sendFailures: s.metrics.sendFailures,
+	}
+}
+
+// 为HTTP连接（WebSocket/SSE）创建客户端：显示的IP支持反向代理，封禁使用连接的对端IP
+func (s *ChatServer) newHTTPClient(r *http.Request) *Client {
+	c := s.newClient(clientIPFromRequest(r))
+	c.remoteIP = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		c.remoteIP = host
+	}
+	return c
+}
+
+// 客户端会话：登录验证、设置ID、收发消息和命令，与传输方式无关
+// 返回时会话结束，由调用方关闭传输层
+func (s *ChatServer) serveClient(client *Client) {
+	if !s.beginSession(client) {
+		return
+	}
+	defer s.endSession(client)
+	clientIP := client.IP
+
+	// 被封禁的IP直接拒绝
+	if s.isBanned(s.banIP(client)) {
+		client.Send(Message{
+			Type:    "system",
+			Content: "【系统通知】你已被管理员封禁，无法进入聊天室",
+			Time:    s.timestamp(),
+		})
+		s.logger.Info("拒绝被封禁的IP", s.logAttrs("ban_reject", "", clientIP)...)
+		return
+	}
+
+	// 开放时段之外拒绝登录，并告知下次开放时间
+	if next, closed := s.closedUntil(); closed {
+		client.Send(Message{
+			Type:    "closed",
+			Content: "【系统通知】聊天室当前不在开放时间，下次开放时间：" + formatOpenTime(next),
+			Time:    s.timestamp(),
+		})
+		s.logger.Info("开放时段之外拒绝连接", s.logAttrs("hours_reject", "", clientIP)...)
+		return
+	}
+
+	// 查询IP归属地（即使解析失败，也不会导致连接断开）
+	clientRegion := s.getIPRegion(clientIP)
+	client.Region = clientRegion
+
+	// 处理IP地址的隐私显示
+	maskedIP := maskIP(clientIP)
+
+	// 第一步：密码验证（增加错误处理，防止客户端异常输入导致断连）
+	client.Send(Message{
+		Type:    "password",
+		Content: "=== 终端聊天室-登录验证 ===\n请输入登录密码：",
+		Time:    s.timestamp(),
+	})
+	for {
+		pwdMsg, err := s.receive(client, "password")
+		if err != nil {
+			s.logger.Info("密码验证阶段连接断开", append(s.logAttrs("login_disconnect", "", clientIP), s.errorAttr(err))...)
+			return
+		}
+		// 过滤空密码
+		pwd := strings.TrimSpace(strings.ToLower(pwdMsg.Content))
+		if pwd == "" {
+			client.Send(Message{
+				Type:    "password",
+				Content: "❌ 密码不能为空！请重新输入：",
+				Time:    s.timestamp(),
+			})
+			continue
+		}
+		if pwd == strings.TrimSpace(strings.ToLower(s.fixedPassword)) {
+			client.Send(Message{
+				Type:    "password",
+				Content: "✅ 密码验证成功！进入用户ID设置环节...",
+				Time:    s.timestamp(),
+			})
+			break
+		} else {
+			s.metrics.loginFailures.Inc()
+			client.Send(Message{
+				Type:    "password",
+				Content: "❌ 密码错误！请重新输入固定登录密码：",
+				Time:    s.timestamp(),
+			})
+		}
+	}
+
+	// 第二步：用户ID设置（增加空ID处理，防止异常输入）
+	client.Send(Message{
+		Type:    "setid",
+		Content: "=== 终端聊天室-用户ID设置 ===\n请输入自定义ID（直接回车则使用随机ID）：",
+		Time:    s.timestamp(),
+	})
+	var userID string
+	for {
+		idMsg, err := s.receive(client, "setid")
+		if err != nil {
+			s.logger.Info("ID设置阶段连接断开", append(s.logAttrs("setid_disconnect", "", clientIP), s.errorAttr(err))...)
+			return
+		}
+		// 过滤换行，防止乱码和注入
+		customID := strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(idMsg.Content, "\n", ""), "\r", ""))
+		// 其他控制字符会被终端当作转义序列，直接拒绝
+		if strings.ContainsFunc(customID, termui.IsControl) {
+			client.Send(Message{
+				Type:    "setid",
+				Content: "❌ 用户ID不能包含控制字符！请重新输入：",
+				Time:    s.timestamp(),
+			})
+			continue
+		}
+		if customID == "" {
+			userID = s.generateRandomID()
+		} else {
+			// HTML 转义，防止 XSS 攻击
+			userID = escapeHTML(customID)
+		}
+		break
+	}
+	// 生成随机颜色
+	color := s.generateRandomColor()
+
+	// 完善客户端信息
+	client.UserID = userID
+	client.Color = color
+
+	// 第三步：验证通过，加入聊天室
+	s.clientsMutex.Lock()
+	s.clients[client.ID] = client
+	onlineCount := len(s.clients)
+	s.clientsMutex.Unlock()
+
+	// 发送欢迎消息
+	now := s.timestamp()
+	welcomeMsg := Message{
+		Type: "welcome",
+		Content: fmt.Sprintf("=== 终端聊天室 %s ===\n✅ 登录成功！当前在线：%d 人\n你的信息：%s | %s | %s\n📌 帮助命令：/help(帮助)",
+			Version, onlineCount, maskedIP, clientRegion, userID),
+		Time: now,
+	}
+	if err := client.Send(welcomeMsg); err != nil {
+		s.logger.Warn("发送欢迎消息失败", append(s.logAttrs("send_error", userID, clientIP), s.errorAttr(err))...)
+		return
+	}
+
+	// 广播加入消息
+	joinMsg := Message{
+		Type:    "join",
+		Content: fmt.Sprintf("【系统】%s | %s | %s 加入聊天室", maskedIP, clientRegion, userID),
+		UserID:  userID,
+		IP:      maskedIP,
+		Region:  clientRegion,
+		Time:    now,
+		Color:   color,
+		Session: client.ID,
+	}
+	s.publish(joinMsg)
+	s.logger.Info("用户加入聊天室", append(s.logAttrs("join", userID, clientIP), "region", clientRegion, "online", onlineCount)...)
+
+	// 第四步：循环接收普通消息/命令（加固错误处理，兼容各种输入）
+	for {
+		msg, err := s.receive(client, "chat")
+		if err != nil {
+			// 客户端异常断开处理，友好广播离开消息
+			s.clientsMutex.Lock()
+			if _, ok := s.clients[client.ID]; ok {
+				delete(s.clients, client.ID)
+				onlineCount = len(s.clients)
+			}
+			s.clientsMutex.Unlock()
+
+			reason := "异常离开聊天室"
+			if client.kicked.Load() {
+				reason = "被管理员踢出聊天室"
+			}
+			leaveMsg := Message{
+				Type:    "leave",
+				Content: fmt.Sprintf("【系统】%s | %s | %s %s", maskedIP, clientRegion, userID, reason),
+				UserID:  userID,
+				IP:      maskedIP,
+				Region:  clientRegion,
+				Time:    s.timestamp(),
+				Color:   color,
+			}
+			s.publish(leaveMsg)
+			s.logger.Info("用户离开聊天室", append(s.logAttrs("leave", userID, clientIP), "region", clientRegion, "online", onlineCount, "kicked", client.kicked.Load())...)
+			return
+		}
+
+		// 补充消息基础信息
+		msg.Time = s.timestamp()
+		msg.UserID = userID
+		msg.IP = maskedIP
+		msg.Region = clientRegion
+		msg.Color = color
+		msg.Session = client.ID
+		inputContent := strings.TrimSpace(msg.Content)
+
+		// 处理命令/普通消息，过滤空消息
+		if inputContent == "/exit" || inputContent == "/quit" {
+			// 主动退出
+			s.clientsMutex.Lock()
+			delete(s.clients, client.ID)
+			onlineCount = len(s.clients)
+			s.clientsMutex.Unlock()
+			leaveMsg := Message{
+				Type:    "leave",
+				Content: fmt.Sprintf("【系统】%s | %s | %s 主动退出聊天室", maskedIP, clientRegion, userID),
+				UserID:  userID,
+				IP:      maskedIP,
+				Region:  clientRegion,
+				Time:    msg.Time,
+				Color:   color,
+			}
+			s.publish(leaveMsg)
+			s.logger.Info("用户主动退出聊天室", append(s.logAttrs("exit", userID, clientIP), "region", clientRegion, "online", onlineCount)...)
+			return
+		} else if inputContent == "/online" {
+			// 在线列表（优化排版，适配长城市名）
+			s.clientsMutex.RLock()
+			onlineList := fmt.Sprintf("=== 在线用户列表（%d人）===\nIP地址         | 城市                    | 用户ID\n----------------|-------------------------|------------------------\n", len(s.clients)+len(s.bots))
+			for _, c := range s.clients {
+				onlineList += fmt.Sprintf("%-15s | %-28s | %s\n", maskIP(c.IP), c.Region, c.UserID)
+			}
+			onlineList += s.botOnlineLines()
+			s.clientsMutex.RUnlock()
+			onlineMsg := Message{
+				Type:    "online",
+				Content: onlineList,
+				Time:    msg.Time,
+			}
+			client.Send(onlineMsg)
+		} else if inputContent == "/help" {
+			// 帮助信息
+			helpMsg := Message{
+				Type:    "help",
+				Content: "=== 终端聊天室-可用命令 ===\n/online - 查看在线用户列表（IP | 归属地 | 用户ID）\n/help   - 显示当前帮助信息\n/exit   - 主动退出聊天室\n/color  - 随机更换自己输入内容的颜色\n/msg 用户ID 内容 - 私聊（只有对方能看到）\n/close [时长] - 设置服务器关闭时间（如 /close 10、/close 90s、/close 1h30m、/close at 18:00），不带参数查看剩余时间\n/echo 内容 - 复读机器人复述内容\n/remind 时长 内容 - 提醒机器人定时提醒（如 /remind 10m 喝水）\n/roll [NdM] - 掷骰子（如 /roll 2d6）\n/flip   - 抛硬币\n/panic  - 老板键：所有人切换到伪装画面\n/resume - 退出老板键模式，恢复显示聊天\n直接输入 - 发送群聊消息（所有在线用户可见）",
+				Time:    msg.Time,
+			}
+			client.Send(helpMsg)
+		} else if inputContent == "/panic" {
+			s.handlePanic(client, msg)
+		} else if inputContent == "/resume" {
+			s.handleResume(client, msg)
+		} else if inputContent == "/color" {
+			// 随机更换颜色
+			newColor := s.generateRandomColor()
+			color = newColor
+			client.Color = newColor
+			colorMsg := Message{
+				Type:    "color",
+				Content: "你已变色！",
+				Time:    msg.Time,
+			}
+			client.Send(colorMsg)
+		} else if args, ok := strings.CutPrefix(inputContent, "/msg "); ok || inputContent == "/msg" {
+			// 私聊
+			s.handlePrivateMessage(client, msg, args)
+		} else if arg, ok := strings.CutPrefix(inputContent, "/close "); ok || inputContent == "/close" {
+			if !ok || strings.TrimSpace(arg) == "" {
+				// 没有参数，显示精确的剩余时间
+				content := "【系统通知】服务器未设置关闭时间"
+				if remaining, ok := s.ShutdownRemaining(); ok {
+					content = fmt.Sprintf("【系统通知】服务器将在 %s 后关闭", formatRemaining(remaining))
+				}
+				client.Send(Message{
+					Type:    "system",
+					Content: content,
+					Time:    msg.Time,
+				})
+				continue
+			}
+			// 有参数，设置关闭时间
+			delay, err := parseShutdownDelay(arg, s.clock.Now())
+			if err != nil {
+				client.Send(Message{
+					Type:    "system",
+					Content: "【系统通知】" + err.Error(),
+					Time:    msg.Time,
+				})
+				continue
+			}
+			s.ScheduleShutdown(delay)
+		} else {
+			// 普通群聊消息，过滤空内容和超长内容
+			content, err := sanitizeChatContent(inputContent)
+			if err == nil && s.isMuted(client) {
+				client.Send(Message{
+					Type:    "system",
+					Content: "【系统通知】你已被管理员禁言，暂时无法发言",
+					Time:    msg.Time,
+				})
+			} else if err == errMessageTooLong || err == errControlChars {
+				client.Send(Message{
+					Type:    "system",
+					Content: "【系统通知】" + err.Error(),
+					Time:    msg.Time,
+				})
+			} else if err == nil {
+				msg.Type = "chat"
+				msg.Content = content
+				s.publish(msg)
+				if s.logPrivacy {
+					s.logger.Debug("收到聊天消息", s.logAttrs("chat", userID, clientIP)...)
+				} else {
+					s.logger.Debug("收到聊天消息", append(s.logAttrs("chat", userID, clientIP), "content", content)...)
+				}
+			}
+		}
+	}
+}
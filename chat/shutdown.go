@@ -0,0 +1,248 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 默认的关闭提醒时间点（距离关闭的时长）
+var DefaultShutdownWarnings = []time.Duration{
+	30 * time.Minute, 10 * time.Minute, 5 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second,
+}
+
+// 关闭时间最长可以设置到多久之后
+const maxShutdownDelay = 24 * time.Hour
+
+// 最终关闭通知发出后，等待广播完成再断开连接
+const shutdownGracePeriod = time.Second
+
+var (
+	errInvalidShutdownDelay = errors.New("请输入有效的关闭时间，例如 /close 10（分钟）、/close 90s、/close 1h30m、/close at 18:00")
+	errShutdownDelayTooLong = fmt.Errorf("关闭时间最长为 %d 小时", int(maxShutdownDelay.Hours()))
+)
+
+// 解析逗号分隔的提醒时间点，如 "30m,10m,5m,1m,30s,10s"；空字符串表示不提醒
+func ParseShutdownWarnings(s string) ([]time.Duration, error) {
+	var warnings []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, fmt.Errorf("提醒时间点 %q 格式错误，应为 30m、1m、10s 等", part)
+		}
+		warnings = append(warnings, d)
+	}
+	return warnings, nil
+}
+
+// 设置关闭提醒时间点（距离关闭的时长），对之后设置的关闭计划生效
+func (s *ChatServer) SetShutdownWarnings(warnings []time.Duration) error {
+	sorted := slices.Clone(warnings)
+	for _, d := range sorted {
+		if d <= 0 {
+			return fmt.Errorf("提醒时间点必须大于0：%s", d)
+		}
+	}
+	// 从远到近排列，去掉重复的时间点
+	slices.SortFunc(sorted, func(a, b time.Duration) int { return int(b - a) })
+	sorted = slices.Compact(sorted)
+
+	s.shutdownMutex.Lock()
+	s.shutdownWarnings = sorted
+	s.shutdownMutex.Unlock()
+	return nil
+}
+
+// 解析 /close 的参数：分钟数（兼容旧用法）、时长（90s、1h30m）或 at 时:分[:秒]（今天已过则为明天）
+func parseShutdownDelay(arg string, now time.Time) (time.Duration, error) {
+	arg = strings.TrimSpace(arg)
+	var d time.Duration
+	if clockTime, ok := strings.CutPrefix(arg, "at "); ok {
+		t, err := parseClockTime(strings.TrimSpace(clockTime), now)
+		if err != nil {
+			return 0, err
+		}
+		d = t.Sub(now)
+	} else if minutes, err := strconv.Atoi(arg); err == nil {
+		// 先比较分钟数再换算，过大的分钟数换算成 time.Duration 会溢出
+		if minutes > int(maxShutdownDelay/time.Minute) {
+			return 0, errShutdownDelayTooLong
+		}
+		d = time.Duration(minutes) * time.Minute
+	} else if d, err = time.ParseDuration(arg); err != nil {
+		return 0, errInvalidShutdownDelay
+	}
+	if d <= 0 {
+		return 0, errInvalidShutdownDelay
+	}
+	if d > maxShutdownDelay {
+		return 0, errShutdownDelayTooLong
+	}
+	return d, nil
+}
+
+// 解析 时:分[:秒]，返回 now 之后最近的该时刻
+func parseClockTime(s string, now time.Time) (time.Time, error) {
+	var t time.Time
+	var err error
+	if strings.Count(s, ":") == 2 {
+		t, err = time.Parse("15:04:05", s)
+	} else {
+		t, err = time.Parse("15:04", s)
+	}
+	if err != nil {
+		return time.Time{}, errInvalidShutdownDelay
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, nil
+}
+
+// 倒计时的中文写法：1小时30分钟、5分钟、1分30秒、30秒
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, sec := int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second)
+	var b strings.Builder
+	if h > 0 {
+		fmt.Fprintf(&b, "%d小时", h)
+	}
+	if m > 0 {
+		if sec > 0 {
+			fmt.Fprintf(&b, "%d分", m)
+		} else {
+			fmt.Fprintf(&b, "%d分钟", m)
+		}
+	}
+	if sec > 0 || b.Len() == 0 {
+		fmt.Fprintf(&b, "%d秒", sec)
+	}
+	return b.String()
+}
+
+// 剩余时间 mm:ss，不足一秒按一秒计
+func formatRemaining(d time.Duration) string {
+	secs := int((d + time.Second - 1) / time.Second)
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
+
+// 发送关闭提醒的系统广播
+func (s *ChatServer) notifyShutdown(content string) {
+	s.publish(Message{
+		Type:    "system",
+		Content: content,
+		Time:    s.timestamp(),
+	})
+}
+
+// 停止所有关闭相关的定时器并清除关闭计划（调用方需持有 shutdownMutex）
+func (s *ChatServer) clearShutdownLocked() {
+	for _, timer := range s.shutdownTimers {
+		timer.Stop()
+	}
+	s.shutdownTimers = nil
+	s.shutdownGen++
+	s.shutdownAt = time.Time{}
+}
+
+// 为当前关闭计划设置一个定时器（调用方需持有 shutdownMutex）
+// 回调持有 shutdownMutex 执行，返回的通知（不为空时）在释放锁之后广播；
+// 定时器触发时关闭计划已被取消或重设则跳过
+func (s *ChatServer) addShutdownTimerLocked(d time.Duration, f func() string) {
+	gen := s.shutdownGen
+	timer := s.clock.AfterFunc(d, func() {
+		s.shutdownMutex.Lock()
+		var notice string
+		if s.shutdownGen == gen {
+			notice = f()
+		}
+		s.shutdownMutex.Unlock()
+		if notice != "" {
+			s.notifyShutdown(notice)
+		}
+	})
+	s.shutdownTimers = append(s.shutdownTimers, timer)
+}
+
+// 为 d 之后的关闭设置提醒：warnings 中早于 d 的每个时间点提前广播一条通知，
+// format 中的 %s 替换为剩余时间；add 为对应计划的定时器设置函数（调用方需持有其锁）
+func (s *ChatServer) scheduleWarnings(d time.Duration, warnings []time.Duration, format string, add func(time.Duration, func() string)) {
+	for _, warning := range warnings {
+		if warning >= d {
+			continue
+		}
+		content := fmt.Sprintf(format, formatCountdown(warning))
+		add(d-warning, func() string { return content })
+	}
+}
+
+// 设置服务器在 d 之后关闭，会覆盖之前的关闭计划
+func (s *ChatServer) ScheduleShutdown(d time.Duration) {
+	s.shutdownMutex.Lock()
+
+	// 取消之前的所有定时器
+	s.clearShutdownLocked()
+
+	// 设置关闭时间
+	s.shutdownAt = s.clock.Now().Add(d)
+
+	// 设置成功通知在释放锁之后广播，广播阻塞时不会卡住 Stop 和其他关闭操作
+	notice := fmt.Sprintf("【系统通知】服务器将在 %s 后关闭（%s）", formatCountdown(d), s.shutdownAt.Format("15:04:05"))
+
+	// 提醒定时器在设置时一次排好，不在回调中嵌套设置
+	s.scheduleWarnings(d, s.shutdownWarnings, "【系统通知】服务器将在%s后关闭，请做好准备！", s.addShutdownTimerLocked)
+
+	// 设置关闭定时器
+	s.addShutdownTimerLocked(d, func() string {
+		// 等待最终关闭消息广播完成后停止聊天室并断开所有连接，嵌入方通过 Done() 得知聊天室已关闭；
+		// 等待期间取消关闭计划仍然有效。Stop 需要 shutdownMutex，在单独的协程中调用
+		s.addShutdownTimerLocked(shutdownGracePeriod, func() string {
+			go s.Stop(context.Background())
+			return ""
+		})
+		return "【系统通知】服务器已关闭，感谢使用！"
+	})
+	s.shutdownMutex.Unlock()
+
+	s.notifyShutdown(notice)
+}
+
+// 取消已设置的关闭计划，返回是否存在被取消的计划
+func (s *ChatServer) CancelShutdown() bool {
+	s.shutdownMutex.Lock()
+	if s.shutdownAt.IsZero() {
+		s.shutdownMutex.Unlock()
+		return false
+	}
+	s.clearShutdownLocked()
+	s.shutdownMutex.Unlock()
+
+	s.notifyShutdown("【系统通知】服务器关闭计划已取消")
+	return true
+}
+
+// 查询关闭计划：剩余时间，以及是否设置了关闭时间
+func (s *ChatServer) ShutdownRemaining() (time.Duration, bool) {
+	s.shutdownMutex.Lock()
+	defer s.shutdownMutex.Unlock()
+	if s.shutdownAt.IsZero() {
+		return 0, false
+	}
+	return max(s.shutdownAt.Sub(s.clock.Now()), 0), true
+}
+
+// 查询计划关闭的时间点，以及是否设置了关闭时间
+func (s *ChatServer) ShutdownAt() (time.Time, bool) {
+	s.shutdownMutex.Lock()
+	defer s.shutdownMutex.Unlock()
+	return s.shutdownAt, !s.shutdownAt.IsZero()
+}
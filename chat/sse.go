@@ -0,0 +1,220 @@
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"demogo/transport"
+)
+
+// SSE 传输：部分公司代理会拦截WebSocket，此时改用
+// GET /sse（服务端 → 客户端的事件流）+ POST /sse/{session}（客户端 → 服务端）
+// 消息格式与WebSocket的JSON编码完全相同，登录流程也一致
+
+const (
+	ssePingInterval   = 15 * time.Second // 心跳间隔，防止代理因空闲断开事件流
+	sseMaxPostSize    = 16 << 10         // 单条POST消息的最大字节数
+	sseInboxSize      = 16               // 尚未被会话读取的客户端消息上限
+	ssePostTimeout    = 5 * time.Second  // 客户端消息排队的最长等待时间
+	sseSessionIDBytes = 16
+)
+
+var errSSEClosed = errors.New("SSE连接已关闭")
+
+// SSE 会话
+type sseTransport struct {
+	mu       sync.Mutex // 串行写事件流，并保护 closed
+	w        io.Writer
+	flusher  http.Flusher
+	closed   bool
+	inbox    chan []byte   // POST 收到的客户端消息
+	done     chan struct{} // 会话关闭时关闭
+	bytesIn  *atomic.Int64 // 线路上实际收到的字节数
+	bytesOut *atomic.Int64 // 线路上实际发出的字节数（含事件格式）
+}
+
+func (t *sseTransport) Name() string { return "sse" }
+
+// 写入一个事件（event 为空时使用默认的 message 事件）
+func (t *sseTransport) writeEvent(event string, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return errSSEClosed
+	}
+	var frame []byte
+	if event != "" {
+		frame = fmt.Appendf(frame, "event: %s\n", event)
+	}
+	frame = fmt.Appendf(frame, "data: %s\n\n", data)
+	n, err := t.w.Write(frame)
+	t.bytesOut.Add(int64(n))
+	if err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// 发送注释行作为心跳
+func (t *sseTransport) ping() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return errSSEClosed
+	}
+	n, err := io.WriteString(t.w, ": ping\n\n")
+	t.bytesOut.Add(int64(n))
+	if err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+// JSON 不含未转义的换行，可以直接放在一行 data 中
+func (t *sseTransport) Write(data []byte) error { return t.writeEvent("", data) }
+
+func (t *sseTransport) WritePrepared(p *transport.Prepared) error { return t.writeEvent("", p.Data) }
+
+func (t *sseTransport) Read() ([]byte, error) {
+	select {
+	case data := <-t.inbox:
+		return data, nil
+	case <-t.done:
+		return nil, errSSEClosed
+	}
+}
+
+func (t *sseTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+	return nil
+}
+
+// 把POST收到的消息交给会话，会话关闭或排队超时则返回错误
+func (t *sseTransport) deliver(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, ssePostTimeout)
+	defer cancel()
+	select {
+	case t.inbox <- data:
+		t.bytesIn.Add(int64(len(data)))
+		return nil
+	case <-t.done:
+		return errSSEClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newSSESessionID() string {
+	b := make([]byte, sseSessionIDBytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 建立SSE事件流，并在当前请求中运行完整的客户端会话
+func (s *ChatServer) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	if !s.checkOrigin(r) {
+		s.rejectOrigin(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持SSE", http.StatusInternalServerError)
+		return
+	}
+
+	client := s.newHTTPClient(r)
+	transport := &sseTransport{
+		w:        w,
+		flusher:  flusher,
+		inbox:    make(chan []byte, sseInboxSize),
+		done:     make(chan struct{}),
+		bytesIn:  &client.WireBytesIn,
+		bytesOut: &client.WireBytesOut,
+	}
+	client.transport = transport
+
+	sessionID := newSSESessionID()
+	s.sseMutex.Lock()
+	s.sseSessions[sessionID] = transport
+	s.sseMutex.Unlock()
+	defer func() {
+		s.sseMutex.Lock()
+		delete(s.sseSessions, sessionID)
+		s.sseMutex.Unlock()
+		transport.Close()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // 禁止 nginx 缓冲事件流
+	w.WriteHeader(http.StatusOK)
+
+	// 第一个事件告知会话编号，之后客户端通过 POST /sse/{session} 发送消息
+	if err := transport.writeEvent("session", fmt.Appendf(nil, `{"session":%q}`, sessionID)); err != nil {
+		return
+	}
+
+	// 客户端断开或会话结束时停止心跳
+	go func() {
+		ticker := s.clock.NewTicker(ssePingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				transport.Close()
+				return
+			case <-transport.done:
+				return
+			case <-ticker.C():
+				if transport.ping() != nil {
+					transport.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	s.logger.Debug("SSE会话建立", s.logAttrs("sse_open", "", client.IP)...)
+	s.serveClient(client)
+}
+
+// 接收SSE会话的客户端消息，消息体与WebSocket消息相同
+func (s *ChatServer) HandleSSEPost(w http.ResponseWriter, r *http.Request) {
+	s.sseMutex.Lock()
+	transport, ok := s.sseSessions[r.PathValue("session")]
+	s.sseMutex.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "会话不存在或已结束"})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, sseMaxPostSize))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "消息过大"})
+		return
+	}
+	if err := transport.deliver(r.Context(), data); err != nil {
+		if errors.Is(err, errSSEClosed) {
+			writeJSON(w, http.StatusGone, apiError{Error: "会话已结束"})
+		} else {
+			writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "服务器繁忙，请稍后重试"})
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
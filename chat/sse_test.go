@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 一个SSE事件
+type sseEvent struct {
+	event string // 为空表示默认的 message 事件
+	data  string
+}
+
+// 脚本化的SSE客户端：GET /sse 读取事件流，POST /sse/{session} 发送消息
+type sseTestClient struct {
+	t       *testing.T
+	ts      *testServer
+	cancel  context.CancelFunc
+	session string
+	events  chan sseEvent // 事件流结束时关闭
+}
+
+func (ts *testServer) dialSSE(t *testing.T) *sseTestClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.httpURL+"/sse", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("建立事件流失败：%v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	c := &sseTestClient{t: t, ts: ts, cancel: cancel, events: make(chan sseEvent, 64)}
+	go func() {
+		defer resp.Body.Close()
+		defer close(c.events)
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev.data != "" {
+					c.events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	first := c.next()
+	var session struct {
+		Session string `json:"session"`
+	}
+	if first.event != "session" || json.Unmarshal([]byte(first.data), &session) != nil || session.Session == "" {
+		t.Fatalf("第一个事件应为会话编号，收到 %+v", first)
+	}
+	c.session = session.Session
+	return c
+}
+
+func (c *sseTestClient) next() sseEvent {
+	c.t.Helper()
+	select {
+	case ev, ok := <-c.events:
+		if !ok {
+			c.t.Fatal("事件流已结束")
+		}
+		return ev
+	case <-time.After(testReadTimeout):
+		c.t.Fatal("等待SSE事件超时")
+		return sseEvent{}
+	}
+}
+
+// 通过 POST /sse/{session} 发送消息，返回状态码
+func (c *sseTestClient) post(v interface{}) int {
+	c.t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	resp, err := http.Post(c.ts.httpURL+"/sse/"+c.session, "application/json", strings.NewReader(string(data)))
+	if err != nil {
+		c.t.Fatalf("发送消息失败：%v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (c *sseTestClient) send(kind, content string) {
+	c.t.Helper()
+	if code := c.post(ChatPayload{Type: kind, Content: content}); code != http.StatusAccepted {
+		c.t.Fatalf("发送 %s 消息返回 %d", kind, code)
+	}
+}
+
+// 读取事件直到出现类型为 kind 且内容包含 contains 的消息
+func (c *sseTestClient) expect(kind, contains string) Message {
+	c.t.Helper()
+	for {
+		ev := c.next()
+		if ev.event != "" {
+			continue
+		}
+		var m Message
+		if err := json.Unmarshal([]byte(ev.data), &m); err != nil {
+			c.t.Fatalf("事件不是合法的消息：%q", ev.data)
+		}
+		if m.Type == kind && strings.Contains(m.Content, contains) {
+			return m
+		}
+	}
+}
+
+func (ts *testServer) sseSessionCount() int {
+	ts.sseMutex.Lock()
+	defer ts.sseMutex.Unlock()
+	return len(ts.sseSessions)
+}
+
+func TestSSESession(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.login(t, "alice")
+
+	c := ts.dialSSE(t)
+	if n := ts.sseSessionCount(); n != 1 {
+		t.Fatalf("SSE会话数 = %d，应为 1", n)
+	}
+	c.expect("password", "请输入登录密码")
+	if code := c.post(HelloPayload{Type: "hello", Versions: []int{ProtocolV2}}); code != http.StatusAccepted {
+		t.Fatalf("发送握手返回 %d", code)
+	}
+	c.expect("hello", "协议 v2")
+	c.send("password", testPassword)
+	c.expect("password", "✅")
+	c.send("setid", "bob")
+	c.expect("welcome", "登录成功")
+	alice.expectJoin("bob")
+
+	// SSE 用户的消息广播给WebSocket用户，也回到自己的事件流
+	c.send("chat", "来自SSE")
+	if m := alice.expect("chat", "来自SSE"); m.UserID != "bob" {
+		t.Errorf("消息的用户 = %q，应为 bob", m.UserID)
+	}
+	c.expect("chat", "来自SSE")
+	alice.chat("来自WebSocket")
+	c.expect("chat", "来自WebSocket")
+
+	// 断开事件流：会话被移除，用户离开聊天室
+	c.cancel()
+	if m := alice.expect("leave", "离开聊天室"); m.UserID != "bob" {
+		t.Errorf("离开消息的用户 = %q，应为 bob", m.UserID)
+	}
+	deadline := time.Now().Add(testReadTimeout)
+	for ts.sseSessionCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("断开后仍有 %d 个SSE会话", ts.sseSessionCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if code := c.post(ChatPayload{Type: "chat", Content: "断开后"}); code != http.StatusNotFound {
+		t.Errorf("会话结束后发送消息返回 %d，应为 404", code)
+	}
+}
@@ -0,0 +1,228 @@
+package chat
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"demogo/termui"
+	"demogo/transport"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// SSH 终端客户端：用 ssh -p 2222 host 在真实终端里聊天
+// SSH 层不做鉴权，连上后和网页一样先输入聊天室密码，再设置用户ID
+// 与WebSocket用户在同一个聊天室，消息按 Client.Color 映射为 ANSI 颜色
+
+const (
+	sshPrompt           = "[root@chat ~]# "
+	sshHandshakeTimeout = 10 * time.Second
+)
+
+// 启动SSH服务（阻塞，Stop 后返回 nil），主机密钥文件不存在时自动生成
+func (s *ChatServer) ListenSSH(addr, hostKeyFile string) error {
+	signer, err := loadOrCreateSSHHostKey(hostKeyFile)
+	if err != nil {
+		return err
+	}
+	config := &ssh.ServerConfig{
+		NoClientAuth:  true, // 由聊天室自己验证密码
+		ServerVersion: "SSH-2.0-chatroom",
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if err := s.addListener(listener); err != nil {
+		return err
+	}
+	s.logger.Info("SSH服务已启动", "event", "ssh_listen", "addr", addr, "fingerprint", ssh.FingerprintSHA256(signer.PublicKey()))
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return s.acceptError(err)
+		}
+		go s.handleSSHConn(conn, config)
+	}
+}
+
+// 读取主机密钥，不存在则生成 ed25519 密钥并保存
+func loadOrCreateSSHHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		block, err := ssh.MarshalPrivateKey(key, "chatroom host key")
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(block)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return nil, fmt.Errorf("保存SSH主机密钥失败：%w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// 处理一个SSH连接：每个连接只允许一个会话
+func (s *ChatServer) handleSSHConn(nConn net.Conn, config *ssh.ServerConfig) {
+	if !s.trackConn(nConn) {
+		return
+	}
+	defer s.untrackConn(nConn)
+	ip, _, err := net.SplitHostPort(nConn.RemoteAddr().String())
+	if err != nil {
+		ip = nConn.RemoteAddr().String()
+	}
+	client := s.newClient(ip)
+
+	// 统计线路上的实际字节数（加密后）
+	conn := &transport.CountingConn{Conn: nConn, BytesRead: &client.WireBytesIn, BytesWritten: &client.WireBytesOut}
+	conn.SetDeadline(time.Now().Add(sshHandshakeTimeout))
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		s.logger.Debug("SSH握手失败", append(s.logAttrs("ssh_handshake_error", "", ip), s.errorAttr(err))...)
+		nConn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	started := false
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "只支持 session")
+			continue
+		}
+		if started {
+			newChannel.Reject(ssh.Prohibited, "每个连接只能打开一个会话")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		started = true
+		go func() {
+			s.serveSSHSession(client, channel, requests)
+			sconn.Close()
+		}()
+	}
+}
+
+// 处理会话请求（终端大小、shell），收到 shell 请求后开始聊天
+func (s *ChatServer) serveSSHSession(client *Client, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	transport := &sshTransport{
+		channel:  channel,
+		term:     term.NewTerminal(channel, sshPrompt),
+		renderer: termui.Renderer{Color: true},
+	}
+
+	shell := make(chan bool, 1) // 收到 shell 请求为 true，会话在此之前结束为 false
+	go func() {
+		started := false
+		for req := range requests {
+			ok := false
+			switch req.Type {
+			case "pty-req":
+				var pty struct {
+					Term          string
+					Columns, Rows uint32
+					Width, Height uint32
+					Modes         string
+				}
+				if ssh.Unmarshal(req.Payload, &pty) == nil {
+					transport.term.SetSize(int(pty.Columns), int(pty.Rows))
+					ok = true
+				}
+			case "window-change":
+				var size struct {
+					Columns, Rows uint32
+					Width, Height uint32
+				}
+				if ssh.Unmarshal(req.Payload, &size) == nil {
+					transport.term.SetSize(int(size.Columns), int(size.Rows))
+					ok = true
+				}
+			case "shell":
+				if !started {
+					shell <- true
+					started = true
+					ok = true
+				}
+			}
+			if req.WantReply {
+				req.Reply(ok, nil)
+			}
+		}
+		if !started {
+			shell <- false
+		}
+	}()
+	if !<-shell {
+		return
+	}
+
+	client.transport = transport
+	client.codec = defaultCodec
+	client.Protocol = ProtocolV1 // 终端输入没有消息类型，按旧版协议只取内容
+	s.logger.Debug("SSH会话建立", s.logAttrs("ssh_open", "", client.IP)...)
+	s.serveClient(client)
+
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+}
+
+// SSH 终端传输：把服务端的 JSON 消息渲染为彩色文本，把输入的每一行作为一条消息
+type sshTransport struct {
+	channel  ssh.Channel
+	term     *term.Terminal
+	renderer termui.Renderer
+	state    terminalState
+}
+
+func (t *sshTransport) Name() string { return "ssh" }
+
+func (t *sshTransport) Write(data []byte) error {
+	msg, err := t.state.observe(data)
+	if err != nil {
+		return err
+	}
+	line := t.renderer.Render(msg)
+	if line == "" {
+		return nil
+	}
+	_, err = t.term.Write([]byte(line + "\n"))
+	return err
+}
+
+func (t *sshTransport) WritePrepared(p *transport.Prepared) error { return t.Write(p.Data) }
+
+func (t *sshTransport) Read() ([]byte, error) {
+	var line string
+	var err error
+	if t.state.password.Load() {
+		line, err = t.term.ReadPassword(sshPrompt)
+	} else {
+		line, err = t.term.ReadLine()
+	}
+	if errors.Is(err, term.ErrPasteIndicator) {
+		err = nil
+	}
+	return t.state.encodeLine(line, err)
+}
+
+func (t *sshTransport) Close() error { return t.channel.Close() }
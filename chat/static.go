@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"demogo/web"
+)
+
+// 小于该大小的资源不压缩
+const gzipMinSize = 1024
+
+// 可压缩的内容类型前缀
+var compressibleTypes = []string{"text/", "application/javascript", "application/json", "image/svg+xml"}
+
+// 一个静态资源及其缓存元数据
+type staticAsset struct {
+	data        []byte
+	gzipped     []byte // 为空表示不压缩
+	etag        string
+	contentType string
+	modTime     time.Time
+}
+
+// 静态资源处理器：支持ETag、缓存头和gzip压缩
+type staticHandler struct {
+	fsys  fs.FS
+	cache bool // 内置资源不会变化，可以缓存；覆盖目录用于主题开发，每次重新读取
+
+	mu     sync.RWMutex
+	assets map[string]*staticAsset
+}
+
+// 使用打包的前端资源；dir 不为空时改用该目录下的文件（便于调试主题）
+func newStaticHandler(dir string) http.Handler {
+	if dir != "" {
+		return &staticHandler{fsys: os.DirFS(dir)}
+	}
+	return &staticHandler{fsys: web.FS, cache: true, assets: make(map[string]*staticAsset)}
+}
+
+// 读取资源，并计算ETag和压缩后的内容
+func (h *staticHandler) load(name string) (*staticAsset, error) {
+	if h.cache {
+		h.mu.RLock()
+		asset, ok := h.assets[name]
+		h.mu.RUnlock()
+		if ok {
+			return asset, nil
+		}
+	}
+
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	asset := &staticAsset{
+		data:        data,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		contentType: mime.TypeByExtension(path.Ext(name)),
+	}
+	if asset.contentType == "" {
+		asset.contentType = http.DetectContentType(data)
+	}
+	if info, err := fs.Stat(h.fsys, name); err == nil {
+		asset.modTime = info.ModTime()
+	}
+	if len(data) >= gzipMinSize && isCompressible(asset.contentType) {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(data)
+		zw.Close()
+		asset.gzipped = buf.Bytes()
+	}
+
+	if h.cache {
+		h.mu.Lock()
+		h.assets[name] = asset
+		h.mu.Unlock()
+	}
+	return asset, nil
+}
+
+func isCompressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按 Accept-Encoding 判断客户端是否接受gzip：q=0 表示明确拒绝，
+// 没有单独列出 gzip 时看通配符 *
+func acceptsGzip(acceptEncoding string) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				var err error
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = max(gzipQ, q)
+		case "*":
+			wildcardQ = max(wildcardQ, q)
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	// 文件不存在、路径非法或是目录时都返回 404
+	asset, err := h.load(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("ETag", asset.etag)
+	header.Set("Vary", "Accept-Encoding")
+	if name == "index.html" {
+		// 页面本身每次都向服务器确认，更新后立即生效
+		header.Set("Cache-Control", "no-cache")
+	} else {
+		header.Set("Cache-Control", "public, max-age=3600")
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, asset.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", asset.contentType)
+	if !asset.modTime.IsZero() {
+		header.Set("Last-Modified", asset.modTime.UTC().Format(http.TimeFormat))
+	}
+	body := asset.data
+	if asset.gzipped != nil && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		header.Set("Content-Encoding", "gzip")
+		body = asset.gzipped
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
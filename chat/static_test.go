@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"gzip, deflate, br", true},
+		{"deflate, gzip;q=0.5", true},
+		{"x-gzip", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.000", false},
+		{"gzip;q=0, deflate", false},
+		{"gzip;q=bad", false},
+		{"deflate, br", false},
+		{"gzipped", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"gzip;q=0, *", false}, // 单独列出的 gzip 优先于通配符
+		{"*;q=0, gzip", true},
+		{"identity;q=1, *;q=0.1", true},
+	} {
+		if got := acceptsGzip(tc.header); got != tc.want {
+			t.Errorf("acceptsGzip(%q) = %v，应为 %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestStaticGzip(t *testing.T) {
+	ts := newTestServer(t)
+	for header, want := range map[string]string{
+		"gzip, deflate": "gzip",
+		"gzip;q=0":      "",
+		"identity":      "",
+	} {
+		req, err := http.NewRequest(http.MethodGet, ts.httpURL+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 手动设置 Accept-Encoding 时 http.Client 不会自动解压
+		req.Header.Set("Accept-Encoding", header)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if got := resp.Header.Get("Content-Encoding"); got != want {
+			t.Errorf("Accept-Encoding %q：Content-Encoding = %q，应为 %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package chat
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+
+	"demogo/termui"
+	"demogo/transport"
+)
+
+// TCP 行协议：可以直接用 nc host 2323 或 telnet host 2323 聊天
+// 每行输入是一条消息，登录流程与网页相同；不支持颜色的终端可以关闭 ANSI 颜色
+
+// 单行输入的最大字节数，超出后断开连接
+const tcpMaxLineSize = 16 << 10
+
+// telnet 协议控制字符
+const (
+	telnetIAC  = 255 // 命令开始
+	telnetSB   = 250 // 子协商开始
+	telnetSE   = 240 // 子协商结束
+	telnetWILL = 251
+	telnetDONT = 254
+)
+
+// 启动TCP行协议服务（阻塞，Stop 后返回 nil）
+func (s *ChatServer) ListenTCP(addr string, color bool) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if err := s.addListener(listener); err != nil {
+		return err
+	}
+	s.logger.Info("TCP行协议服务已启动", "event", "tcp_listen", "addr", addr, "color", color)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return s.acceptError(err)
+		}
+		go s.handleTCPConn(conn, color)
+	}
+}
+
+// 处理一个TCP连接，会话结束后关闭连接
+func (s *ChatServer) handleTCPConn(nConn net.Conn, color bool) {
+	if !s.trackConn(nConn) {
+		return
+	}
+	defer s.untrackConn(nConn)
+	ip, _, err := net.SplitHostPort(nConn.RemoteAddr().String())
+	if err != nil {
+		ip = nConn.RemoteAddr().String()
+	}
+	client := s.newClient(ip)
+	conn := &transport.CountingConn{Conn: nConn, BytesRead: &client.WireBytesIn, BytesWritten: &client.WireBytesOut}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 1024), tcpMaxLineSize)
+	client.transport = &lineTransport{
+		conn:     conn,
+		scanner:  scanner,
+		renderer: termui.Renderer{Color: color},
+	}
+	client.codec = defaultCodec
+	client.Protocol = ProtocolV1 // 终端输入没有消息类型，按旧版协议只取内容
+	s.logger.Debug("TCP会话建立", s.logAttrs("tcp_open", "", client.IP)...)
+	s.serveClient(client)
+}
+
+// TCP 行协议传输：输出渲染后的文本行（CRLF 换行，兼容 telnet），输入按行读取
+type lineTransport struct {
+	conn     net.Conn
+	scanner  *bufio.Scanner
+	renderer termui.Renderer
+	state    terminalState
+}
+
+func (t *lineTransport) Name() string { return "tcp" }
+
+func (t *lineTransport) Write(data []byte) error {
+	msg, err := t.state.observe(data)
+	if err != nil {
+		return err
+	}
+	line := t.renderer.Render(msg)
+	if line == "" {
+		return nil
+	}
+	_, err = t.conn.Write([]byte(strings.ReplaceAll(line, "\n", "\r\n") + "\r\n"))
+	return err
+}
+
+func (t *lineTransport) WritePrepared(p *transport.Prepared) error { return t.Write(p.Data) }
+
+func (t *lineTransport) Read() ([]byte, error) {
+	if !t.scanner.Scan() {
+		err := t.scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		return t.state.encodeLine("", err)
+	}
+	return t.state.encodeLine(stripTelnetCommands(t.scanner.Text()), nil)
+}
+
+func (t *lineTransport) Close() error { return t.conn.Close() }
+
+// 去掉 telnet 客户端发送的协商命令和行尾的 \r
+func stripTelnetCommands(line string) string {
+	if strings.IndexByte(line, telnetIAC) < 0 {
+		return strings.TrimRight(line, "\r")
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != telnetIAC || i+1 >= len(line) {
+			b.WriteByte(line[i])
+			continue
+		}
+		switch cmd := line[i+1]; {
+		case cmd == telnetIAC: // 转义的 255
+			b.WriteByte(telnetIAC)
+			i++
+		case cmd == telnetSB: // 跳过整个子协商
+			end := strings.Index(line[i:], string([]byte{telnetIAC, telnetSE}))
+			if end < 0 {
+				i = len(line)
+			} else {
+				i += end + 1
+			}
+		case cmd >= telnetWILL && cmd <= telnetDONT: // WILL/WONT/DO/DONT 带一个选项字节
+			i += 2
+		default:
+			i++
+		}
+	}
+	return strings.TrimRight(b.String(), "\r")
+}
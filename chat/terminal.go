@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"encoding/json"
+	"io"
+	"sync/atomic"
+)
+
+// 终端客户端（SSH、TCP/telnet）的公共部分：消息渲染见 termui 包，输入的每一行作为一条消息
+
+// 终端会话的登录阶段：终端输入没有消息类型，根据服务端发出的提示判断当前阶段
+type terminalState struct {
+	password atomic.Bool // 密码输入阶段，输入内容不应回显
+	loggedIn atomic.Bool // 已登录，此后输入结束（Ctrl-D）视为 /exit
+	exited   bool        // 已把输入结束转换为 /exit（只在读取协程中访问）
+}
+
+// 解析要发给终端的消息（终端会话固定使用JSON编码），并更新登录阶段
+func (st *terminalState) observe(data []byte) (Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, err
+	}
+	switch msg.Type {
+	case "password":
+		st.password.Store(true)
+	case "setid":
+		st.password.Store(false)
+	case "welcome":
+		st.loggedIn.Store(true)
+	}
+	return msg, nil
+}
+
+// 把读到的一行输入编码为客户端消息；登录后第一次读到输入结束时转换为 /exit，正常退出聊天室
+func (st *terminalState) encodeLine(line string, err error) ([]byte, error) {
+	if err == io.EOF && st.loggedIn.Load() && !st.exited {
+		st.exited = true
+		line, err = "/exit", nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{Content: line})
+}
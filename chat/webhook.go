@@ -0,0 +1,235 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"demogo/clock"
+)
+
+// 出站Webhook配置（JSON配置文件中的一项）
+type WebhookConfig struct {
+	URL        string   `json:"url"`        // 接收地址
+	Secret     string   `json:"secret"`     // HMAC-SHA256签名密钥（为空则不签名）
+	Events     []string `json:"events"`     // 订阅的事件：join/leave/chat/system，为空表示全部
+	MaxRetries int      `json:"maxRetries"` // 失败重试次数，默认3次
+}
+
+// Webhook推送的JSON内容
+type WebhookPayload struct {
+	Event     string  `json:"event"`     // 事件类型（同 Message.Type）
+	Timestamp int64   `json:"timestamp"` // 事件发生的Unix时间戳
+	Message   Message `json:"message"`   // 原始广播消息
+}
+
+// 支持推送的事件类型
+var webhookEvents = map[string]bool{"join": true, "leave": true, "chat": true, "system": true}
+
+// 签名请求头，值为 "sha256=" + 十六进制HMAC
+const webhookSignatureHeader = "X-Chat-Signature"
+
+// 单个Webhook的投递队列
+type webhook struct {
+	config WebhookConfig
+	events map[string]bool
+	queue  chan WebhookPayload
+}
+
+// 出站Webhook分发器：异步投递，不阻塞广播
+type WebhookDispatcher struct {
+	hooks       []*webhook
+	client      *http.Client
+	logger      *slog.Logger
+	clock       clock.Clock
+	baseBackoff time.Duration // 首次重试等待时间，之后每次翻倍
+
+	startOnce sync.Once
+	ctx       context.Context // Close 后取消，结束投递协程和进行中的请求
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// 从JSON文件加载Webhook配置
+func LoadWebhookConfigs(path string) ([]WebhookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []WebhookConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("解析Webhook配置失败: %w", err)
+	}
+	for _, c := range configs {
+		if c.URL == "" {
+			return nil, fmt.Errorf("Webhook配置缺少url")
+		}
+		for _, e := range c.Events {
+			if !webhookEvents[e] {
+				return nil, fmt.Errorf("Webhook %s 订阅了不支持的事件：%s", c.URL, e)
+			}
+		}
+	}
+	return configs, nil
+}
+
+// 新建Webhook分发器；投递协程在 SetWebhooks 时启动，使用聊天室的日志记录器和时钟
+func NewWebhookDispatcher(configs []WebhookConfig, client *http.Client) *WebhookDispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &WebhookDispatcher{
+		client:      client,
+		logger:      slog.Default(),
+		clock:       clock.Real,
+		baseBackoff: time.Second,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	for _, c := range configs {
+		if c.MaxRetries <= 0 {
+			c.MaxRetries = 3
+		}
+		h := &webhook{
+			config: c,
+			events: make(map[string]bool),
+			queue:  make(chan WebhookPayload, 100),
+		}
+		for _, e := range c.Events {
+			h.events[e] = true
+		}
+		d.hooks = append(d.hooks, h)
+	}
+	return d
+}
+
+// 为每个Webhook启动投递协程；重复调用无效
+func (d *WebhookDispatcher) start(logger *slog.Logger, c clock.Clock) {
+	d.startOnce.Do(func() {
+		d.logger = logger
+		d.clock = c
+		for _, h := range d.hooks {
+			d.wg.Add(1)
+			go d.deliverLoop(h)
+		}
+	})
+}
+
+// 停止投递：取消进行中的请求和重试，等待投递协程结束，队列中尚未投递的事件被丢弃
+func (d *WebhookDispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.cancel()
+	d.wg.Wait()
+}
+
+// 发布一条广播消息（非阻塞，队列满时丢弃并记录日志）
+func (d *WebhookDispatcher) Publish(msg Message) {
+	if d == nil || !webhookEvents[msg.Type] || d.ctx.Err() != nil {
+		return
+	}
+	payload := WebhookPayload{
+		Event:     msg.Type,
+		Timestamp: d.clock.Now().Unix(),
+		Message:   msg,
+	}
+	for _, h := range d.hooks {
+		if len(h.events) > 0 && !h.events[msg.Type] {
+			continue
+		}
+		select {
+		case h.queue <- payload:
+		default:
+			d.logger.Warn("Webhook投递队列已满，丢弃事件", "event", "webhook_drop", "url", h.config.URL, "type", msg.Type)
+		}
+	}
+}
+
+// 投递循环：按顺序投递，失败时指数退避重试
+func (d *WebhookDispatcher) deliverLoop(h *webhook) {
+	defer d.wg.Done()
+	for {
+		var payload WebhookPayload
+		select {
+		case payload = <-h.queue:
+		case <-d.ctx.Done():
+			return
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			d.logger.Error("Webhook序列化失败", "event", "webhook_error", "error", err)
+			continue
+		}
+		backoff := d.baseBackoff
+		for attempt := 0; ; attempt++ {
+			err := d.send(h, payload.Event, body)
+			if err == nil {
+				break
+			}
+			if d.ctx.Err() != nil {
+				return
+			}
+			if attempt >= h.config.MaxRetries {
+				d.logger.Error("Webhook投递失败，已放弃", "event", "webhook_error", "url", h.config.URL, "type", payload.Event, "error", err)
+				break
+			}
+			if !d.sleep(backoff) {
+				return
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// 等待 wait 后返回 true；分发器关闭则提前返回 false
+func (d *WebhookDispatcher) sleep(wait time.Duration) bool {
+	wake := make(chan struct{})
+	timer := d.clock.AfterFunc(wait, func() { close(wake) })
+	defer timer.Stop()
+	select {
+	case <-wake:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// 发送一次POST请求，非2xx状态视为失败
+func (d *WebhookDispatcher) send(h *webhook, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, h.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Chat-Event", event)
+	if h.config.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(h.config.Secret, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("接口返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 计算请求体的HMAC-SHA256签名（十六进制）
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
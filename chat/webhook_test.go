@@ -0,0 +1,170 @@
+package chat
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"demogo/clock"
+)
+
+// 收到的一次Webhook请求
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// 记录请求的Webhook接收端，前 failures 次请求返回 500
+func newWebhookReceiver(t *testing.T, failures int32) (*httptest.Server, <-chan webhookRequest) {
+	t.Helper()
+	requests := make(chan webhookRequest, 16)
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- webhookRequest{header: r.Header.Clone(), body: body}
+		if count.Add(1) <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+// 启动使用假时钟的分发器，测试结束时关闭
+func startTestDispatcher(t *testing.T, fake *clock.Fake, configs ...WebhookConfig) *WebhookDispatcher {
+	t.Helper()
+	d := NewWebhookDispatcher(configs, nil)
+	d.start(slog.New(slog.NewTextHandler(io.Discard, nil)), fake)
+	t.Cleanup(d.Close)
+	return d
+}
+
+func nextWebhookRequest(t *testing.T, requests <-chan webhookRequest) webhookRequest {
+	t.Helper()
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(testReadTimeout):
+		t.Fatal("等待Webhook请求超时")
+		return webhookRequest{}
+	}
+}
+
+func noWebhookRequest(t *testing.T, requests <-chan webhookRequest) {
+	t.Helper()
+	select {
+	case r := <-requests:
+		t.Fatalf("不应收到Webhook请求：%s", r.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// 等待假时钟上出现重试的定时器
+func waitPending(t *testing.T, fake *clock.Fake, n int) {
+	t.Helper()
+	deadline := time.Now().Add(testReadTimeout)
+	for fake.Pending() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待定时器超时：当前 %d 个，需要 %d 个", fake.Pending(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookSignatureAndEvents(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	srv, requests := newWebhookReceiver(t, 0)
+	d := startTestDispatcher(t, fake, WebhookConfig{URL: srv.URL, Secret: "hook-secret", Events: []string{"chat"}})
+
+	// 未订阅的 join 和不推送的私聊都应被过滤，只投递两条聊天消息
+	d.Publish(Message{Type: "join", UserID: "alice"})
+	d.Publish(Message{Type: "private", UserID: "alice", Content: "悄悄话"})
+	d.Publish(Message{Type: "chat", UserID: "alice", Content: "你好"})
+	d.Publish(Message{Type: "chat", UserID: "bob", Content: "再见"})
+
+	for _, want := range []string{"你好", "再见"} {
+		r := nextWebhookRequest(t, requests)
+		if got := r.header.Get(webhookSignatureHeader); got != "sha256="+signWebhook("hook-secret", r.body) {
+			t.Errorf("签名错误：%q", got)
+		}
+		if got := r.header.Get("X-Chat-Event"); got != "chat" {
+			t.Errorf("X-Chat-Event = %q，应为 chat", got)
+		}
+		var payload WebhookPayload
+		if err := json.Unmarshal(r.body, &payload); err != nil {
+			t.Fatalf("请求体不是合法JSON：%v", err)
+		}
+		if payload.Event != "chat" || payload.Message.Content != want {
+			t.Errorf("推送内容 = %+v，应为聊天消息 %q", payload, want)
+		}
+		if payload.Timestamp != fake.Now().Unix() {
+			t.Errorf("时间戳 = %d，应取自时钟 %d", payload.Timestamp, fake.Now().Unix())
+		}
+	}
+	noWebhookRequest(t, requests)
+
+	// 未设置密钥时不签名
+	plain, plainRequests := newWebhookReceiver(t, 0)
+	d = startTestDispatcher(t, fake, WebhookConfig{URL: plain.URL})
+	d.Publish(Message{Type: "system", Content: "公告"})
+	if r := nextWebhookRequest(t, plainRequests); r.header.Get(webhookSignatureHeader) != "" {
+		t.Errorf("未设置密钥时不应有签名头")
+	}
+}
+
+func TestWebhookRetryBackoff(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	srv, requests := newWebhookReceiver(t, 2)
+	d := startTestDispatcher(t, fake, WebhookConfig{URL: srv.URL, MaxRetries: 3})
+
+	d.Publish(Message{Type: "chat", Content: "重试"})
+	first := nextWebhookRequest(t, requests)
+
+	// 第一次失败后等待 1s，第二次失败后等待 2s
+	for _, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		waitPending(t, fake, 1)
+		fake.Advance(backoff - time.Millisecond)
+		noWebhookRequest(t, requests)
+		fake.Advance(time.Millisecond)
+		if r := nextWebhookRequest(t, requests); string(r.body) != string(first.body) {
+			t.Errorf("重试的请求体应与首次相同：%s", r.body)
+		}
+	}
+	// 第三次成功，不再重试
+	time.Sleep(50 * time.Millisecond)
+	if n := fake.Pending(); n != 0 {
+		t.Errorf("投递成功后仍有 %d 个重试定时器", n)
+	}
+	fake.Advance(time.Minute)
+	noWebhookRequest(t, requests)
+}
+
+func TestWebhookCloseStopsRetries(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	srv, requests := newWebhookReceiver(t, 100)
+	d := startTestDispatcher(t, fake, WebhookConfig{URL: srv.URL, MaxRetries: 5})
+
+	d.Publish(Message{Type: "chat", Content: "失败"})
+	nextWebhookRequest(t, requests)
+	waitPending(t, fake, 1)
+
+	// 正在等待重试的投递协程应随 Close 结束
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(testReadTimeout):
+		t.Fatal("Close 没有结束投递协程")
+	}
+	fake.Advance(time.Minute)
+	d.Publish(Message{Type: "chat", Content: "关闭后"})
+	noWebhookRequest(t, requests)
+}
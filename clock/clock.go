@@ -0,0 +1,43 @@
+// Package clock 抽象当前时间和定时器，聊天室的倒计时、禁言、提醒等功能都通过它计时，
+// 测试中使用 Fake 手动推进时间
+package clock
+
+import "time"
+
+// 时钟
+type Clock interface {
+	Now() time.Time
+	// 在 d 之后调用 f（在单独的协程中，Fake 则在 Advance 中同步调用）
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// 定时器
+type Timer interface {
+	// 取消定时器，返回定时器是否尚未触发
+	Stop() bool
+}
+
+// 周期定时器
+type Ticker interface {
+	// 每个周期收到一次当前时间，处理不及时的周期会被丢弃
+	C() <-chan time.Time
+	Stop()
+}
+
+// 系统时钟
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,117 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// 假时钟：时间只在 Advance 时前进，到期的定时器按到期顺序在 Advance 中同步执行
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// 定时器和周期定时器共用：周期定时器到期后按周期重新排期
+type fakeTimer struct {
+	clock  *Fake
+	when   time.Time
+	f      func()
+	period time.Duration
+	c      chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	return c.add(&fakeTimer{clock: c, when: c.Now().Add(d), f: f})
+}
+
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: 周期必须为正数")
+	}
+	return fakeTicker{c.add(&fakeTimer{clock: c, when: c.Now().Add(d), period: d, c: make(chan time.Time, 1)})}
+}
+
+func (c *Fake) add(t *fakeTimer) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// 等待中的定时器数量（含周期定时器），测试可据此确认定时器已设置
+func (c *Fake) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// 时间前进 d，期间到期的定时器（包括回调中新设置的）按到期顺序执行
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		next := -1
+		for i, t := range c.timers {
+			if !t.when.After(target) && (next < 0 || t.when.Before(c.timers[next].when)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[next]
+		c.now = t.when
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			c.timers = slices.Delete(c.timers, next, next+1)
+		}
+		now := c.now
+		c.mu.Unlock()
+
+		if t.f != nil {
+			t.f()
+		} else {
+			select {
+			case t.c <- now:
+			default:
+			}
+		}
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	return true
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t fakeTicker) Stop() { t.fakeTimer.Stop() }
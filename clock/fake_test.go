@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFakeAfterFuncOrder(t *testing.T) {
+	c := NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	var fired []string
+	c.AfterFunc(3*time.Minute, func() { fired = append(fired, "3m") })
+	c.AfterFunc(time.Minute, func() {
+		fired = append(fired, "1m")
+		// 回调中设置的定时器在同一次 Advance 内到期也会执行
+		c.AfterFunc(time.Minute, func() { fired = append(fired, "1m+1m") })
+	})
+	stopped := c.AfterFunc(2*time.Minute, func() { fired = append(fired, "stopped") })
+	if !stopped.Stop() {
+		t.Fatal("未触发的定时器 Stop 应返回 true")
+	}
+
+	c.AfterFunc(0, func() { fired = append(fired, "0") })
+	c.Advance(150 * time.Second)
+	if want := []string{"0", "1m", "1m+1m"}; !slices.Equal(fired, want) {
+		t.Fatalf("触发顺序 = %v，应为 %v", fired, want)
+	}
+	if got := c.Now().Sub(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)); got != 150*time.Second {
+		t.Fatalf("Advance 后时间前进了 %v", got)
+	}
+	c.Advance(30 * time.Second)
+	if fired[len(fired)-1] != "3m" || c.Pending() != 0 {
+		t.Fatalf("触发 = %v，剩余定时器 %d 个", fired, c.Pending())
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	c := NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	ticker := c.NewTicker(10 * time.Second)
+
+	c.Advance(9 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("周期未到不应触发")
+	default:
+	}
+
+	// 跨越多个周期时和 time.Ticker 一样只保留第一次未处理的触发
+	c.Advance(25 * time.Second)
+	if got := <-ticker.C(); !got.Equal(time.Date(2024, 3, 1, 12, 0, 10, 0, time.UTC)) {
+		t.Fatalf("触发时间 = %v", got)
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("未处理的触发应被丢弃")
+	default:
+	}
+
+	ticker.Stop()
+	c.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Fatal("Stop 后不应触发")
+	default:
+	}
+}
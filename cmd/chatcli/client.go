@@ -0,0 +1,298 @@
+package main
+
+import (
+	"html"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"demogo/protocol"
+	"demogo/termui"
+	"github.com/gorilla/websocket"
+	"golang.org/x/term"
+)
+
+// 重连退避：从 minBackoff 开始每次翻倍，最多 maxBackoff
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// 聊天室客户端：管理连接、登录阶段和断线重连
+type chatClient struct {
+	url      string
+	dialer   *websocket.Dialer
+	term     *term.Terminal
+	renderer termui.Renderer
+	prompt   string
+
+	mu       sync.Mutex // 保护以下字段，并串行写连接
+	conn     *websocket.Conn
+	phase    string          // 当前阶段：password/setid/chat，决定输入作为哪种消息发送
+	password string          // 登录密码，登录成功后保存用于重连
+	userID   string          // 用户ID，登录成功后保存用于重连
+	pending  string          // 已发送、尚未确认的密码或用户ID
+	autoSent bool            // 本次连接已自动发送过密码
+	online   map[string]bool // 在线用户ID，用于 Tab 补全
+	quiet    bool            // 不显示下一条在线列表（登录后自动拉取）
+	exiting  bool
+
+	prompted chan struct{} // 收到新的登录阶段提示
+	quit     chan struct{} // 退出时关闭，中断重连等待
+}
+
+func newChatClient(url string, t *term.Terminal, renderer termui.Renderer) *chatClient {
+	return &chatClient{
+		url:      url,
+		dialer:   &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		term:     t,
+		renderer: renderer,
+		online:   make(map[string]bool),
+		prompted: make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+	}
+}
+
+// 输出一行（term.Terminal 会在输出后重绘当前输入行）
+func (c *chatClient) println(line string) {
+	c.term.Write([]byte(line + "\n"))
+}
+
+func (c *chatClient) notice(text string) {
+	c.println(c.renderer.Colorize("*** "+text, "#888888", false))
+}
+
+func (c *chatClient) currentPhase() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.phase
+}
+
+// 连接并处理消息，断线后按指数退避重连，直到主动退出
+func (c *chatClient) run() {
+	backoff := minBackoff
+	for {
+		c.notice("正在连接 " + c.url + " ...")
+		conn, _, err := c.dialer.Dial(c.url, nil)
+		if err == nil {
+			loggedIn := c.serve(conn)
+			if c.isExiting() {
+				return
+			}
+			if loggedIn {
+				backoff = minBackoff
+			}
+			c.notice("与服务器断开连接")
+		} else {
+			c.notice("连接失败：" + err.Error())
+		}
+
+		// 加入随机抖动，避免服务重启后所有客户端同时重连
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		c.notice("将在 " + wait.Round(time.Second).String() + " 后重连")
+		select {
+		case <-time.After(wait):
+		case <-c.quit:
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// 处理一个连接直到断开，返回本次连接是否登录成功
+func (c *chatClient) serve(conn *websocket.Conn) bool {
+	c.mu.Lock()
+	c.conn = conn
+	c.phase = "password"
+	c.autoSent = false
+	c.pending = ""
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	// 握手：告知服务端支持的协议版本
+	c.send("hello", "")
+	loggedIn := false
+	for {
+		var msg protocol.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return loggedIn
+		}
+		if msg.Type == "welcome" {
+			loggedIn = true
+		}
+		c.handle(msg)
+	}
+}
+
+// 发送一条消息，hello 消息携带协议版本
+func (c *chatClient) send(kind, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendLocked(kind, content)
+}
+
+func (c *chatClient) sendLocked(kind, content string) {
+	if c.conn == nil {
+		return
+	}
+	if kind == "hello" {
+		c.conn.WriteJSON(protocol.HelloPayload{Type: kind, Versions: []int{protocol.CurrentVersion}})
+		return
+	}
+	c.conn.WriteJSON(protocol.ChatPayload{Type: kind, Content: content})
+}
+
+// 处理服务端消息：更新登录阶段和在线列表，自动完成重连登录，然后显示
+func (c *chatClient) handle(msg protocol.Message) {
+	c.mu.Lock()
+	show := true
+	switch msg.Type {
+	case "password":
+		c.phase = "password"
+		switch {
+		case strings.HasPrefix(msg.Content, "✅"):
+			c.password = c.pending
+		case strings.HasPrefix(msg.Content, "❌") && c.autoSent:
+			// 保存的密码已失效，改为手动输入
+			c.password = ""
+			c.signalPrompt()
+		case c.password != "" && !c.autoSent:
+			c.autoSent = true
+			c.pending = c.password
+			c.sendLocked("password", c.password)
+		default:
+			// 需要用户输入（验证成功后紧接着是ID设置提示，等那条提示再读取输入）
+			if !strings.HasPrefix(msg.Content, "✅") {
+				c.signalPrompt()
+			}
+		}
+	case "setid":
+		c.phase = "setid"
+		if c.userID != "" {
+			c.pending = c.userID
+			c.sendLocked("setid", c.userID)
+		} else {
+			c.signalPrompt()
+		}
+	case "welcome":
+		c.phase = "chat"
+		c.userID = c.pending
+		if c.userID == "" {
+			c.userID = userIDFromWelcome(msg.Content)
+		}
+		// 拉取在线列表用于补全，不显示
+		c.quiet = true
+		c.sendLocked("chat", "/online")
+		c.signalPrompt()
+	case "join":
+		c.online[html.UnescapeString(msg.UserID)] = true
+	case "leave":
+		delete(c.online, html.UnescapeString(msg.UserID))
+	case "online":
+		c.online = parseOnlineList(msg.Content)
+		if c.quiet {
+			c.quiet = false
+			show = false
+		}
+	}
+	c.mu.Unlock()
+
+	if line := c.renderer.Render(msg); show && line != "" {
+		c.println(line)
+	}
+}
+
+// 通知输入协程需要用户输入（不阻塞）
+func (c *chatClient) signalPrompt() {
+	select {
+	case c.prompted <- struct{}{}:
+	default:
+	}
+}
+
+// 发送用户输入；登录阶段等待服务端回应后再读取下一行，以便按新阶段决定是否隐藏输入
+func (c *chatClient) submit(line string) {
+	c.mu.Lock()
+	if c.conn == nil {
+		c.mu.Unlock()
+		c.notice("未连接到服务器，消息未发送")
+		return
+	}
+	phase := c.phase
+	trimmed := strings.TrimSpace(line)
+	if phase == "chat" && (trimmed == "/exit" || trimmed == "/quit") {
+		c.exiting = true
+	}
+	if phase != "chat" {
+		c.pending = strings.TrimSpace(line)
+		// 清掉之前残留的通知
+		select {
+		case <-c.prompted:
+		default:
+		}
+	}
+	c.sendLocked(phase, line)
+	c.mu.Unlock()
+
+	if phase != "chat" {
+		select {
+		case <-c.prompted:
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (c *chatClient) isExiting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.exiting
+}
+
+// 退出：断开连接并停止重连
+func (c *chatClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exiting = true
+	select {
+	case <-c.quit:
+	default:
+		close(c.quit)
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// 从欢迎消息“你的信息：IP | 城市 | 用户ID”中取出随机分配的用户ID
+func userIDFromWelcome(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if info, ok := strings.CutPrefix(line, "你的信息："); ok {
+			fields := strings.Split(info, " | ")
+			return html.UnescapeString(strings.TrimSpace(fields[len(fields)-1]))
+		}
+	}
+	return ""
+}
+
+// 解析 /online 的在线列表，每行格式为“IP | 城市 | 用户ID”，机器人带 [BOT] 后缀
+func parseOnlineList(content string) map[string]bool {
+	online := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(line, " | ")
+		if len(fields) != 3 {
+			continue
+		}
+		id := strings.TrimSpace(strings.TrimSuffix(fields[2], "[BOT]"))
+		if id == "" || id == "用户ID" {
+			continue
+		}
+		online[html.UnescapeString(id)] = true
+	}
+	return online
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"demogo/chat"
+	"demogo/termui"
+	"golang.org/x/term"
+)
+
+func TestWrongPasswordPromptsForInput(t *testing.T) {
+	server := chat.NewChatServer("Secret")
+	server.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	server.Start()
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	// 终端没有输入，输出丢弃
+	input, _ := io.Pipe()
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, io.Discard}, prompt)
+	c := newChatClient("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", terminal, termui.Renderer{})
+	c.password = "wrong" // 相当于 -password wrong
+
+	done := make(chan struct{})
+	go func() {
+		c.run()
+		close(done)
+	}()
+	defer func() {
+		c.close()
+		<-done
+	}()
+
+	// 自动发送的密码错误后，输入协程应收到提示，改为让用户输入
+	select {
+	case <-c.prompted:
+	case <-time.After(3 * time.Second):
+		t.Fatal("密码错误后没有提示用户重新输入")
+	}
+	if phase := c.currentPhase(); phase != "password" {
+		t.Errorf("当前阶段为 %q，期望 password", phase)
+	}
+	c.mu.Lock()
+	password := c.password
+	c.mu.Unlock()
+	if password != "" {
+		t.Errorf("失效的密码没有清除：%q", password)
+	}
+}
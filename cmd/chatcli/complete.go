@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// 可补全的命令（与服务端 /help 一致）
+var commands = []string{
+	"/online", "/help", "/exit", "/quit", "/color", "/msg", "/close",
+	"/echo", "/remind", "/roll", "/flip", "/panic", "/resume",
+}
+
+// Tab 补全：行首的 / 开头补全命令，其他位置补全在线用户ID
+// 有多个候选时补全公共前缀并列出候选
+func (c *chatClient) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := strings.LastIndexByte(line[:pos], ' ') + 1
+	word := line[start:pos]
+
+	var candidates []string
+	if start == 0 && strings.HasPrefix(word, "/") {
+		candidates = commands
+	} else {
+		c.mu.Lock()
+		for id := range c.online {
+			candidates = append(candidates, id)
+		}
+		c.mu.Unlock()
+		sort.Strings(candidates)
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := matches[0]
+	if len(matches) == 1 {
+		completion += " "
+	} else {
+		for _, m := range matches[1:] {
+			completion = commonPrefix(completion, m)
+		}
+		// 补全回调运行时终端处于加锁状态，候选列表需要异步输出
+		go c.println(strings.Join(matches, "  "))
+	}
+	newLine := line[:start] + completion + line[pos:]
+	return newLine, start + len(completion), true
+}
+
+// 两个字符串的公共前缀（按字符，避免截断多字节字符）
+func commonPrefix(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return string(ra[:n])
+}
@@ -0,0 +1,103 @@
+// chatcli 是终端聊天室的命令行客户端：连接 /ws，在终端中完成登录和聊天
+//
+//	go run ./cmd/chatcli -url ws://localhost:18080/ws
+//
+// 支持命令历史（上下键）、Tab 补全命令和在线用户ID、断线自动重连
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"demogo/termui"
+	"golang.org/x/term"
+)
+
+const prompt = "[root@chat ~]# "
+
+func main() {
+	url := flag.String("url", "ws://localhost:18080/ws", "聊天室WebSocket地址")
+	password := flag.String("password", "", "登录密码（为空则连接后输入）")
+	userID := flag.String("id", "", "用户ID（为空则连接后输入）")
+	insecure := flag.Bool("insecure", false, "不校验服务端证书（自签名证书时使用）")
+	noColor := flag.Bool("no-color", false, "不输出 ANSI 颜色")
+	flag.Parse()
+
+	// 终端切换到原始模式，由 term.Terminal 负责行编辑、历史和补全
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "无法设置终端：", err)
+			os.Exit(1)
+		}
+		defer term.Restore(fd, oldState)
+	}
+	renderer := termui.Renderer{Color: !*noColor}
+	coloredPrompt := renderer.Colorize(prompt, "#00ffff", true)
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, coloredPrompt)
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		terminal.SetSize(width, height)
+	}
+
+	c := newChatClient(*url, terminal, renderer)
+	c.prompt = coloredPrompt
+	c.dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	c.password = *password
+	c.userID = *userID
+	terminal.AutoCompleteCallback = c.complete
+
+	done := make(chan struct{})
+	go func() {
+		c.run()
+		close(done)
+	}()
+	c.inputLoop(done)
+}
+
+// 读取用户输入并发送，直到退出聊天室
+func (c *chatClient) inputLoop(done <-chan struct{}) {
+	// 等到服务端第一次要求输入（或自动登录完成）再读取，以便密码输入不回显
+	select {
+	case <-c.prompted:
+	case <-done:
+		return
+	}
+	for {
+		var line string
+		var err error
+		if c.currentPhase() == "password" {
+			line, err = c.term.ReadPassword(c.prompt)
+		} else {
+			line, err = c.term.ReadLine()
+		}
+		if err != nil {
+			// Ctrl-C / Ctrl-D：已登录时正常退出聊天室
+			if c.currentPhase() != "chat" {
+				c.close()
+				return
+			}
+			line = "/exit"
+		}
+		line = strings.TrimRight(line, "\r\n")
+		c.submit(line)
+		if trimmed := strings.TrimSpace(line); trimmed == "/exit" || trimmed == "/quit" {
+			// 等服务端处理退出后断开，超时则直接断开
+			select {
+			case <-done:
+			case <-time.After(3 * time.Second):
+				c.close()
+				<-done
+			}
+			return
+		}
+	}
+}
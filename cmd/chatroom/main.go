@@ -0,0 +1,209 @@
+// chatroom 是终端聊天室的服务端程序：网页、SSH、TCP 和 IRC 客户端共用同一个聊天室
+//
+//	go run ./cmd/chatroom -ssh-addr :2222
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"demogo/chat"
+)
+
+func main() {
+	// ====================== 请确认你的固定登录密码 ======================
+	fixedPassword := "123" // 可直接修改为你需要的密码，建议使用包含大小写字母、数字和特殊字符的复杂密码
+	// =====================================================================
+
+	// 命令行参数
+	webhooksFile := flag.String("webhooks", "", "出站Webhook配置文件（JSON数组）")
+	apiToken := flag.String("api-token", "", "入站消息接口的Bearer Token（为空则关闭接口）")
+	adminToken := flag.String("admin-token", "", "管理接口的Bearer Token（为空则关闭接口）")
+	var logConfig chat.LogConfig
+	flag.StringVar(&logConfig.Format, "log-format", "text", "日志格式：text 或 json")
+	flag.StringVar(&logConfig.Level, "log-level", "info", "日志级别：debug/info/warn/error")
+	flag.BoolVar(&logConfig.Privacy, "log-privacy", false, "隐私模式：日志中不记录真实IP和消息内容")
+	tlsCert := flag.String("tls-cert", "", "TLS证书文件路径（与 -tls-key 同时设置时启用HTTPS）")
+	tlsKey := flag.String("tls-key", "", "TLS私钥文件路径")
+	tlsSelfSigned := flag.Bool("tls-self-signed", false, "证书或私钥文件不存在时生成局域网使用的自签名证书")
+	allowedOrigins := flag.String("allowed-origins", "", "同源之外允许连接WebSocket的来源，逗号分隔，支持 *.example.com 通配子域名")
+	trustedProxies := flag.String("trusted-proxies", "", "可信的反向代理IP或网段，逗号分隔；只有来自这些地址的连接才按 X-Forwarded-For 封禁")
+	webDir := flag.String("web-dir", "", "前端资源目录，结构同 web/static（为空则使用打包进程序的页面，调试主题时使用）")
+	compression := chat.DefaultCompressionConfig
+	flag.BoolVar(&compression.Enabled, "compression", compression.Enabled, "允许协商 permessage-deflate 压缩")
+	flag.IntVar(&compression.Level, "compression-level", compression.Level, "压缩级别 1（最快）~ 9（最小）")
+	flag.IntVar(&compression.Threshold, "compression-threshold", compression.Threshold, "小于该字节数的消息不压缩")
+	sshAddr := flag.String("ssh-addr", "", "SSH终端客户端的监听地址（例如 :2222，为空则不启用）")
+	sshHostKey := flag.String("ssh-host-key", "ssh_host_ed25519_key", "SSH主机密钥文件（不存在时自动生成）")
+	tcpAddr := flag.String("tcp-addr", "", "TCP行协议（nc/telnet）的监听地址（例如 :2323，为空则不启用）")
+	tcpColor := flag.Bool("tcp-color", true, "TCP行协议输出 ANSI 颜色")
+	ircAddr := flag.String("irc-addr", "", "IRC网关的监听地址（例如 :6667，为空则不启用）")
+	shutdownWarnings := flag.String("shutdown-warnings", "30m,10m,5m,1m,30s,10s", "关闭前的提醒时间点，逗号分隔（为空则不提醒）")
+	openHours := flag.String("open-hours", "", "开放时段，多个用 ; 分隔（例如 \"mon-fri 12:00-13:30\"，为空则不限制）")
+	httpRedirect := flag.String("http-redirect", "", "启用HTTPS时，在该地址监听HTTP并重定向到HTTPS（例如 :18081）")
+	printSchema := flag.Bool("print-schema", false, "输出WebSocket协议的JSON Schema后退出")
+	flag.Parse()
+
+	if *printSchema {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(chat.ProtocolSchema())
+		return
+	}
+
+	// 初始化结构化日志
+	logger, err := chat.NewLogger(os.Stderr, logConfig)
+	if err != nil {
+		log.Fatalf("日志配置错误：%v", err)
+	}
+	slog.SetDefault(logger)
+
+	// 初始化聊天室
+	server := chat.NewChatServer(fixedPassword)
+	server.SetLogger(logger, logConfig.Privacy)
+	server.SetAllowedOrigins(chat.ParseAllowedOrigins(*allowedOrigins))
+	proxies, err := chat.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		logger.Error("可信代理配置错误", "error", err)
+		os.Exit(1)
+	}
+	server.SetTrustedProxies(proxies)
+	server.SetAPIToken(*apiToken)
+	server.SetAdminToken(*adminToken)
+	if err := server.SetWebDir(*webDir); err != nil {
+		logger.Error("加载前端资源失败", "error", err)
+		os.Exit(1)
+	}
+	if err := server.SetCompression(compression); err != nil {
+		logger.Error("压缩配置错误", "error", err)
+		os.Exit(1)
+	}
+	warnings, err := chat.ParseShutdownWarnings(*shutdownWarnings)
+	if err == nil {
+		err = server.SetShutdownWarnings(warnings)
+	}
+	if err != nil {
+		logger.Error("关闭提醒配置错误", "error", err)
+		os.Exit(1)
+	}
+	hours, err := chat.ParseOpenHours(*openHours)
+	if err != nil {
+		logger.Error("开放时段配置错误", "error", err)
+		os.Exit(1)
+	}
+	server.SetOpenHours(hours)
+	if *webhooksFile != "" {
+		configs, err := chat.LoadWebhookConfigs(*webhooksFile)
+		if err != nil {
+			logger.Error("加载Webhook配置失败", "error", err)
+			os.Exit(1)
+		}
+		server.SetWebhooks(chat.NewWebhookDispatcher(configs, nil))
+		logger.Info("已加载出站Webhook", "count", len(configs))
+	}
+	// 启动广播协程
+	server.Start()
+
+	// 注册内置机器人
+	server.AddBot(chat.NewEchoBot())
+	server.AddBot(chat.NewRemindBot())
+	server.AddBot(chat.NewDiceBot())
+
+	// SSH终端客户端
+	if *sshAddr != "" {
+		go func() {
+			if err := server.ListenSSH(*sshAddr, *sshHostKey); err != nil {
+				logger.Error("SSH服务启动失败", "addr", *sshAddr, "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// TCP行协议（nc/telnet）
+	if *tcpAddr != "" {
+		go func() {
+			if err := server.ListenTCP(*tcpAddr, *tcpColor); err != nil {
+				logger.Error("TCP行协议服务启动失败", "addr", *tcpAddr, "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// IRC网关
+	if *ircAddr != "" {
+		go func() {
+			if err := server.ListenIRC(*ircAddr); err != nil {
+				logger.Error("IRC网关启动失败", "addr", *ircAddr, "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// 启动服务，监听18080端口（增加端口占用检测）
+	port := "18080"
+	if *tlsSelfSigned && (*tlsCert == "" || *tlsKey == "") {
+		*tlsCert, *tlsKey = "cert.pem", "key.pem"
+	}
+	useTLS := *tlsCert != "" && *tlsKey != ""
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	logger.Info("终端聊天室 "+chat.Version+" 启动成功", "event", "startup", "addr", scheme+"://localhost:"+port, "log_privacy", logConfig.Privacy)
+
+	// 创建HTTP服务器实例，以便后续可以关闭
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
+	}
+
+	// /close 到期后聊天室停止，随后关闭HTTP服务，程序正常退出
+	go func() {
+		<-server.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	// 启动服务器（配置了证书则启用HTTPS，证书支持热加载）
+	if useTLS {
+		if *tlsSelfSigned {
+			if err := generateSelfSignedCert(*tlsCert, *tlsKey); err != nil {
+				logger.Error("生成自签名证书失败", "error", err)
+				os.Exit(1)
+			}
+		}
+		reloader, err := newCertReloader(*tlsCert, *tlsKey, logger)
+		if err != nil {
+			logger.Error("加载TLS证书失败", "error", err)
+			os.Exit(1)
+		}
+		go reloader.watch()
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		if *httpRedirect != "" {
+			go func() {
+				if err := http.ListenAndServe(*httpRedirect, redirectToHTTPS(port)); err != nil {
+					logger.Error("HTTP重定向服务启动失败", "addr", *httpRedirect, "error", err)
+				}
+			}()
+		}
+		logger.Info("已启用HTTPS", "event", "tls_enabled", "cert", *tlsCert, "http_redirect", *httpRedirect)
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		logger.Error("服务启动失败，请检查18080端口是否被占用", "error", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+// Package geo 查询IP归属地（太平洋网络公开接口），本地/内网IP直接返回提示
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// 太平洋网络IP接口返回结构体（JSON格式）
+type PConlineIPResp struct {
+	Ip       string `json:"ip"`
+	Pro      string `json:"pro"`
+	ProCode  string `json:"proCode"`
+	City     string `json:"city"`
+	CityCode string `json:"cityCode"`
+	Isp      string `json:"isp"`
+}
+
+// GBK转UTF-8 核心函数（解决中文乱码）
+func GbkToUtf8(s []byte) ([]byte, error) {
+	reader := transform.NewReader(strings.NewReader(string(s)), simplifiedchinese.GBK.NewDecoder())
+	d, e := io.ReadAll(reader)
+	if e != nil {
+		return nil, e
+	}
+	return d, nil
+}
+
+// 查询IP归属地【最终版】：GBK转UTF-8 + 太平洋网络接口 + 本地/内网兼容
+// 第二个返回值为查询结果分类：local/ok/unknown/network_error/http_error/decode_error
+func Lookup(ip string) (string, string) {
+	// 第一步：兼容本地/内网IP，直接返回友好提示
+	localIPPrefixes := []string{"127.0.0.1", "192.168.", "10.", "172."}
+	for _, prefix := range localIPPrefixes {
+		if strings.HasPrefix(ip, prefix) {
+			return "本地/内网IP-无公网归属", "local"
+		}
+	}
+
+	// 第二步：太平洋网络公开IP接口（JSON格式，无反爬）
+	apiUrl := fmt.Sprintf("http://whois.pconline.com.cn/ipJson.jsp?ip=%s&json=true", ip)
+	client := &http.Client{
+		Timeout: 5 * time.Second, // 延长超时时间，防止网络抖动
+	}
+	resp, err := client.Get(apiUrl)
+	if err != nil {
+		return "归属地查询-网络超时", "network_error"
+	}
+	defer resp.Body.Close()
+
+	// 读取GBK编码的响应体
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != 200 {
+		return "归属地查询-接口返回失败", "http_error"
+	}
+
+	// 第三步：核心-GBK转UTF-8，彻底解决中文乱码
+	utf8Body, err := GbkToUtf8(body)
+	if err != nil {
+		// 转码失败兜底，直接返回原解析结果
+		utf8Body = body
+	}
+
+	// 第四步：解析UTF-8格式的JSON数据
+	var ipResp PConlineIPResp
+	if err := json.Unmarshal(utf8Body, &ipResp); err != nil {
+		return "归属地查询-解析失败", "decode_error"
+	}
+
+	// 第五步：只返回城市信息，空值兜底处理
+	city := strings.TrimSpace(ipResp.City)
+	if city == "" || city == "null" {
+		return "未知城市", "unknown"
+	}
+
+	return city, "ok"
+}
@@ -0,0 +1,49 @@
+// Package protocol 定义终端聊天室的消息结构，服务端和 cmd/chatcli 客户端共用
+// 协议说明见 docs/PROTOCOL.md，desc 标签用于生成 JSON Schema
+package protocol
+
+// 协议版本
+const (
+	V1 = 1 // 旧版：没有握手，服务端只读取 content 字段，不做校验
+	V2 = 2 // 握手协商版本，按阶段校验消息类型，拒绝未知字段
+
+	CurrentVersion = V2
+)
+
+// 消息结构体（前端<->后端通信格式）
+type Message struct {
+	Type    string `json:"type" desc:"消息类型"` // hello/error/password/setid/chat/join/leave/online/help/color/system/welcome/panic/resume
+	Content string `json:"content" desc:"消息内容/密码/用户ID"`
+	UserID  string `json:"userId" desc:"发送者用户ID"`
+	IP      string `json:"ip" desc:"发送者IP（已脱敏）"`
+	Region  string `json:"region" desc:"发送者IP归属地"`
+	Time    string `json:"time" desc:"服务端时间 HH:MM:SS"`
+	Color   string `json:"color" desc:"发送者颜色"`
+	Version int    `json:"version,omitempty" desc:"协商后的协议版本（仅 hello 消息）"`
+	Session uint64 `json:"-"` // 发送者的会话编号，只在服务端内部用于识别消息来源，不编码
+}
+
+// 握手消息：连接建立后客户端发送的第一条消息，列出客户端支持的协议版本
+type HelloPayload struct {
+	Type     string   `json:"type" desc:"固定为 hello"`
+	Versions []int    `json:"versions" desc:"客户端支持的协议版本"`
+	Features []string `json:"features,omitempty" desc:"客户端支持的可选功能（如 panic 老板键）"`
+}
+
+// 登录密码
+type PasswordPayload struct {
+	Type    string `json:"type" desc:"固定为 password"`
+	Content string `json:"content" desc:"登录密码"`
+}
+
+// 设置用户ID，为空则使用随机ID
+type SetIDPayload struct {
+	Type    string `json:"type" desc:"固定为 setid"`
+	Content string `json:"content" desc:"自定义用户ID，为空则使用随机ID"`
+}
+
+// 聊天消息或以 / 开头的命令
+type ChatPayload struct {
+	Type    string `json:"type" desc:"固定为 chat"`
+	Content string `json:"content" desc:"聊天内容或命令（如 /online）"`
+}
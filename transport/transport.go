@@ -0,0 +1,66 @@
+// Package transport 定义聊天室客户端的传输层接口，
+// 登录流程和命令处理与具体传输方式（WebSocket/SSE/SSH/TCP/IRC）无关
+package transport
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// 客户端传输层
+type Transport interface {
+	// 传输方式名称（websocket/sse/ssh/tcp/irc）
+	Name() string
+	// 发送一条已编码的消息
+	Write(data []byte) error
+	// 发送预编码的广播消息
+	WritePrepared(p *Prepared) error
+	// 阻塞读取客户端发来的下一条消息，连接关闭后返回错误
+	Read() ([]byte, error)
+	// 关闭连接，可重复调用
+	Close() error
+}
+
+// 预编码的广播消息：同一条消息发给多个客户端时复用编码结果和WebSocket帧
+type Prepared struct {
+	Data      []byte
+	FrameType int                        // WebSocket帧类型（文本或二进制）
+	pm        *websocket.PreparedMessage // 首次发给WebSocket客户端时生成
+}
+
+func NewPrepared(data []byte, frameType int) *Prepared {
+	return &Prepared{Data: data, FrameType: frameType}
+}
+
+// 获取（必要时生成）WebSocket帧数据，只在广播协程中调用
+func (p *Prepared) WebSocketFrame() (*websocket.PreparedMessage, error) {
+	if p.pm == nil {
+		pm, err := websocket.NewPreparedMessage(p.FrameType, p.Data)
+		if err != nil {
+			return nil, err
+		}
+		p.pm = pm
+	}
+	return p.pm, nil
+}
+
+// 统计线路上实际收发字节数的连接（含帧头，压缩后的大小）
+type CountingConn struct {
+	net.Conn
+	BytesRead    *atomic.Int64
+	BytesWritten *atomic.Int64
+}
+
+func (c *CountingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.BytesRead.Add(int64(n))
+	return n, err
+}
+
+func (c *CountingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.BytesWritten.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,87 @@
+// Package ws 是基于 gorilla/websocket 的传输层实现
+package ws
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"demogo/transport"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket传输
+type Transport struct {
+	conn              *websocket.Conn
+	frameType         int  // 按协商的编码格式选择文本帧或二进制帧
+	compressed        bool // 是否协商了 permessage-deflate
+	compressThreshold int  // 小于该字节数的消息不压缩
+}
+
+func New(conn *websocket.Conn, frameType int) *Transport {
+	return &Transport{conn: conn, frameType: frameType}
+}
+
+// 启用 permessage-deflate（握手时已协商成功），小于 threshold 字节的消息不压缩
+func (t *Transport) EnableCompression(level, threshold int) error {
+	if err := t.conn.SetCompressionLevel(level); err != nil {
+		return err
+	}
+	t.compressed = true
+	t.compressThreshold = threshold
+	return nil
+}
+
+// 是否协商了压缩
+func (t *Transport) Compressed() bool { return t.compressed }
+
+func (t *Transport) Name() string { return "websocket" }
+
+func (t *Transport) Write(data []byte) error {
+	t.applyCompression(len(data))
+	return t.conn.WriteMessage(t.frameType, data)
+}
+
+func (t *Transport) WritePrepared(p *transport.Prepared) error {
+	pm, err := p.WebSocketFrame()
+	if err != nil {
+		return err
+	}
+	t.applyCompression(len(p.Data))
+	return t.conn.WritePreparedMessage(pm)
+}
+
+func (t *Transport) Read() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+func (t *Transport) Close() error { return t.conn.Close() }
+
+// 按消息大小决定本次写入是否压缩（调用方需串行写入）
+func (t *Transport) applyCompression(size int) {
+	if t.compressed {
+		t.conn.EnableWriteCompression(size >= t.compressThreshold)
+	}
+}
+
+// 包装 ResponseWriter，让WebSocket升级时接管的连接经过 transport.CountingConn
+type CountingResponseWriter struct {
+	http.ResponseWriter
+	BytesRead    *atomic.Int64
+	BytesWritten *atomic.Int64
+}
+
+func (w *CountingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter 不支持 Hijack")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &transport.CountingConn{Conn: conn, BytesRead: w.BytesRead, BytesWritten: w.BytesWritten}, brw, nil
+}
@@ -0,0 +1,23 @@
+// Package web 打包聊天室的前端资源
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// static 目录下的全部文件（页面、样式、脚本、图片等）都会打包进程序
+//
+//go:embed static
+var static embed.FS
+
+// 前端资源，以 static 目录为根（index.html 即 static/index.html）
+var FS = mustSub(static, "static")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}